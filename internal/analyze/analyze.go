@@ -0,0 +1,240 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"squish/internal/utils"
+	"strings"
+)
+
+// Metafile is the part of an esbuild metafile the analysis needs
+type Metafile struct {
+	Outputs map[string]struct {
+		Bytes  int `json:"bytes"`
+		Inputs map[string]struct {
+			BytesInOutput int `json:"bytesInOutput"`
+		} `json:"inputs"`
+	} `json:"outputs"`
+}
+
+// Report is the analysis of the outputs described by a set of metafiles
+type Report struct {
+	Outputs []OutputReport
+}
+
+// OutputReport describes what an output file consists of
+type OutputReport struct {
+	Path  string
+	Bytes int
+	// Modules are the input modules in the output, largest first
+	Modules []Contribution
+	// Packages are the packages the modules belong to, largest first, with the package's own
+	// modules grouped under "(project)"
+	Packages []Contribution
+	// Duplicates are packages included from more than one location
+	Duplicates []Duplicate
+}
+
+type Contribution struct {
+	Name  string
+	Bytes int
+}
+
+type Duplicate struct {
+	Name   string
+	Copies []PackageCopy
+}
+
+// PackageCopy is one location a package is included from
+type PackageCopy struct {
+	Dir     string
+	Version string
+	Bytes   int
+}
+
+// projectPackage groups the modules that are not part of a dependency
+const projectPackage = "(project)"
+
+// ReadMetafiles analyzes the outputs of metafiles. Outputs present in several metafiles, like
+// chunks shared between entries, are reported once. Source maps are left out.
+func ReadMetafiles(paths []string) (*Report, error) {
+	report := &Report{}
+	seen := make(map[string]bool)
+
+	for _, metafilePath := range paths {
+		data, err := os.ReadFile(metafilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		var meta Metafile
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("%s: %w", metafilePath, err)
+		}
+
+		outputPaths := make([]string, 0, len(meta.Outputs))
+		for outputPath := range meta.Outputs {
+			outputPaths = append(outputPaths, outputPath)
+		}
+		sort.Strings(outputPaths)
+
+		for _, outputPath := range outputPaths {
+			if seen[outputPath] || strings.HasSuffix(outputPath, ".map") {
+				continue
+			}
+			seen[outputPath] = true
+
+			output := meta.Outputs[outputPath]
+			inputs := make(map[string]int, len(output.Inputs))
+			for input, contribution := range output.Inputs {
+				inputs[input] = contribution.BytesInOutput
+			}
+			report.Outputs = append(report.Outputs, analyzeOutput(outputPath, output.Bytes, inputs))
+		}
+	}
+
+	return report, nil
+}
+
+func analyzeOutput(outputPath string, bytes int, inputs map[string]int) OutputReport {
+	report := OutputReport{Path: outputPath, Bytes: bytes}
+
+	packageBytes := make(map[string]int)
+	// copies maps package names to the directories they are included from
+	copies := make(map[string]map[string]int)
+
+	for input, inputBytes := range inputs {
+		report.Modules = append(report.Modules, Contribution{Name: input, Bytes: inputBytes})
+
+		name, dir, ok := packageOf(input)
+		if !ok {
+			packageBytes[projectPackage] += inputBytes
+			continue
+		}
+		packageBytes[name] += inputBytes
+		if copies[name] == nil {
+			copies[name] = make(map[string]int)
+		}
+		copies[name][dir] += inputBytes
+	}
+
+	for name, b := range packageBytes {
+		report.Packages = append(report.Packages, Contribution{Name: name, Bytes: b})
+	}
+	sortContributions(report.Modules)
+	sortContributions(report.Packages)
+
+	for name, dirs := range copies {
+		if len(dirs) < 2 {
+			continue
+		}
+		duplicate := Duplicate{Name: name}
+		for dir, b := range dirs {
+			duplicate.Copies = append(duplicate.Copies, PackageCopy{Dir: dir, Version: packageVersion(dir), Bytes: b})
+		}
+		sort.Slice(duplicate.Copies, func(i, j int) bool {
+			return duplicate.Copies[i].Dir < duplicate.Copies[j].Dir
+		})
+		report.Duplicates = append(report.Duplicates, duplicate)
+	}
+	sort.Slice(report.Duplicates, func(i, j int) bool {
+		return report.Duplicates[i].Name < report.Duplicates[j].Name
+	})
+
+	return report
+}
+
+func sortContributions(contributions []Contribution) {
+	sort.Slice(contributions, func(i, j int) bool {
+		if contributions[i].Bytes != contributions[j].Bytes {
+			return contributions[i].Bytes > contributions[j].Bytes
+		}
+		return contributions[i].Name < contributions[j].Name
+	})
+}
+
+// packageOf returns the package an input module belongs to and the directory it is installed in,
+// using the innermost node_modules directory of its path.
+func packageOf(input string) (string, string, bool) {
+	parts := strings.Split(filepath.ToSlash(input), "/")
+
+	i := len(parts) - 1
+	for i >= 0 && parts[i] != "node_modules" {
+		i--
+	}
+	if i < 0 || i+1 >= len(parts)-1 {
+		return "", "", false
+	}
+
+	end := i + 2
+	if strings.HasPrefix(parts[i+1], "@") && i+2 < len(parts)-1 {
+		end = i + 3
+	}
+	return strings.Join(parts[i+1:end], "/"), path.Join(parts[:end]...), true
+}
+
+// packageVersion reads the version of the package installed in dir, if it can be found
+func packageVersion(dir string) string {
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	data, err := os.ReadFile(filepath.Join(filepath.FromSlash(dir), "package.json"))
+	if err != nil || json.Unmarshal(data, &pkg) != nil {
+		return ""
+	}
+	return pkg.Version
+}
+
+// Print writes the report as text, listing at most top modules and packages per output.
+func (r *Report) Print(w io.Writer, top int) {
+	for i, output := range r.Outputs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s  %s\n", output.Path, utils.FormatBytes(output.Bytes))
+
+		fmt.Fprintln(w, "  Top modules:")
+		printContributions(w, output.Modules, output.Bytes, top)
+
+		fmt.Fprintln(w, "  Top packages:")
+		printContributions(w, output.Packages, output.Bytes, top)
+
+		if len(output.Duplicates) > 0 {
+			fmt.Fprintln(w, "  Duplicate packages:")
+			for _, duplicate := range output.Duplicates {
+				fmt.Fprintf(w, "    %s\n", duplicate.Name)
+				for _, c := range duplicate.Copies {
+					version := c.Version
+					if version == "" {
+						version = "unknown version"
+					}
+					fmt.Fprintf(w, "      %10s  %s (%s)\n", utils.FormatBytes(c.Bytes), c.Dir, version)
+				}
+			}
+		}
+	}
+}
+
+func printContributions(w io.Writer, contributions []Contribution, total, top int) {
+	if len(contributions) == 0 {
+		fmt.Fprintln(w, "    (none)")
+		return
+	}
+
+	for i, c := range contributions {
+		if top > 0 && i == top {
+			fmt.Fprintf(w, "    ... %d more\n", len(contributions)-top)
+			break
+		}
+		share := 0.0
+		if total > 0 {
+			share = float64(c.Bytes) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "    %10s  %5.1f%%  %s\n", utils.FormatBytes(c.Bytes), share, c.Name)
+	}
+}
@@ -0,0 +1,76 @@
+package analyze
+
+import (
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"os"
+)
+
+//go:embed treemap.html
+var treemapTemplate string
+
+// treemapNode is a node of the treemap: an output, a package or a module
+type treemapNode struct {
+	Name     string         `json:"name"`
+	Bytes    int            `json:"bytes"`
+	Children []*treemapNode `json:"children,omitempty"`
+}
+
+// WriteHTML writes a self-contained HTML page with a treemap of the outputs, grouping the modules
+// of every output by package.
+func (r *Report) WriteHTML(path string) error {
+	tmpl, err := template.New("treemap").Parse(treemapTemplate)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(r.treemap())
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// template.JS keeps the JSON unescaped inside the script element, while json.Marshal already
+	// escapes the characters that could end it
+	return tmpl.Execute(file, template.JS(data))
+}
+
+func (r *Report) treemap() []*treemapNode {
+	outputs := make([]*treemapNode, 0, len(r.Outputs))
+
+	for _, output := range r.Outputs {
+		node := &treemapNode{Name: output.Path, Bytes: output.Bytes}
+		packages := make(map[string]*treemapNode)
+
+		for _, module := range output.Modules {
+			name, _, ok := packageOf(module.Name)
+			if !ok {
+				name = projectPackage
+			}
+
+			pkg := packages[name]
+			if pkg == nil {
+				pkg = &treemapNode{Name: name}
+				packages[name] = pkg
+			}
+			pkg.Bytes += module.Bytes
+			pkg.Children = append(pkg.Children, &treemapNode{Name: module.Name, Bytes: module.Bytes})
+		}
+
+		// Packages keep the order of the report, largest first
+		for _, pkg := range output.Packages {
+			if packages[pkg.Name] != nil {
+				node.Children = append(node.Children, packages[pkg.Name])
+			}
+		}
+		outputs = append(outputs, node)
+	}
+
+	return outputs
+}
@@ -0,0 +1,325 @@
+package check
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"squish/internal/config"
+	"strings"
+)
+
+// Problem is an issue consumers of the package run into, located by the JSON path in package.json
+// of the field causing it
+type Problem struct {
+	Path    string
+	Message string
+	// Environments are the condition combinations the problem shows up in, e.g. "node require"
+	Environments []string
+	// Warning is set for problems that may be intended, which do not fail the check
+	Warning bool
+}
+
+func (p Problem) String() string {
+	message := p.Message
+	if p.Warning {
+		message = "warning: " + message
+	}
+	if len(p.Environments) == 0 {
+		return fmt.Sprintf("%s: %s", p.Path, message)
+	}
+	return fmt.Sprintf("%s: %s (%s)", p.Path, message, strings.Join(p.Environments, ", "))
+}
+
+// checker collects the problems of a package, merging the same problem found in several environments
+type checker struct {
+	dir         string
+	packageType config.PackageType
+	// formats maps the output files squish built to their format
+	formats  map[string]config.PackageType
+	problems []*Problem
+	index    map[string]*Problem
+}
+
+func (c *checker) report(path, message string, env *environment) {
+	key := path + "\x00" + message
+	problem, ok := c.index[key]
+	if !ok {
+		problem = &Problem{Path: path, Message: message}
+		c.index[key] = problem
+		c.problems = append(c.problems, problem)
+	}
+	if env != nil {
+		problem.Environments = append(problem.Environments, env.String())
+	}
+}
+
+// warn reports a problem that may be intended
+func (c *checker) warn(path, message string, env *environment) {
+	c.report(path, message, env)
+	c.index[path+"\x00"+message].Warning = true
+}
+
+// Package checks the package.json in dir the way consumers resolve it: every exports subpath is
+// resolved for each combination of import or require, node or browser and TypeScript, and the
+// files it resolves to have to exist and be loaded in the format they were built in. formats maps
+// the output paths squish built, relative to dir, to their format.
+func Package(dir string, formats map[string]config.PackageType) ([]Problem, error) {
+	pkg, packageType, err := readPackage(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checker{
+		dir:         dir,
+		packageType: packageType,
+		formats:     make(map[string]config.PackageType, len(formats)),
+		index:       make(map[string]*Problem),
+	}
+	for output, format := range formats {
+		c.formats[filepath.ToSlash(filepath.Clean(output))] = format
+	}
+
+	c.checkFields(pkg)
+
+	if exports, ok := pkg.values["exports"]; ok {
+		c.checkConditionOrder(exports, "exports", 0)
+		c.checkExports(exports, hasTypes(pkg))
+	}
+
+	problems := make([]Problem, 0, len(c.problems))
+	for _, problem := range c.problems {
+		problems = append(problems, *problem)
+	}
+	return problems, nil
+}
+
+// readPackage reads the package.json in dir, keeping the order of its keys, and returns the
+// format of its .js files.
+func readPackage(dir string) (*object, config.PackageType, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return nil, "", err
+	}
+	parsed, err := parseOrdered(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing package.json: %w", err)
+	}
+	pkg, ok := parsed.(*object)
+	if !ok {
+		return nil, "", fmt.Errorf("package.json is not an object")
+	}
+
+	if t, _ := pkg.values["type"].(string); t == string(config.PackageTypeModule) {
+		return pkg, config.PackageTypeModule, nil
+	}
+	return pkg, config.PackageTypeCommonJS, nil
+}
+
+// checkFields checks the entry points outside of exports, which older tools resolve.
+func (c *checker) checkFields(pkg *object) {
+	for _, field := range []string{"main", "module", "types", "typings"} {
+		target, ok := pkg.values[field].(string)
+		if !ok || target == "" {
+			continue
+		}
+		if !c.exists(target) {
+			c.report(field, fmt.Sprintf("%s does not exist", target), nil)
+			continue
+		}
+		if field == "main" {
+			c.checkFormat(field, target, nil)
+		}
+	}
+}
+
+// checkConditionOrder reports condition objects in which types is not the first condition or
+// default is not the last, as resolvers use the first condition that matches.
+func (c *checker) checkConditionOrder(value interface{}, path string, depth int) {
+	switch v := value.(type) {
+	case *object:
+		for i, key := range v.keys {
+			switch {
+			case strings.HasPrefix(key, "."):
+				if depth > 0 {
+					c.report(config.JSONPath(path, key), "subpaths are only allowed at the top level of exports", nil)
+				}
+			case key == "types" && i > 0:
+				c.report(config.JSONPath(path, key), "\"types\" must be the first condition, TypeScript uses the first condition it matches", nil)
+			case key == "default" && i < len(v.keys)-1:
+				c.report(config.JSONPath(path, key), "\"default\" must be the last condition, the conditions after it are never used", nil)
+			}
+			c.checkConditionOrder(v.values[key], config.JSONPath(path, key), depth+1)
+		}
+	case []interface{}:
+		for i, item := range v {
+			c.checkConditionOrder(item, fmt.Sprintf("%s[%d]", path, i), depth+1)
+		}
+	}
+}
+
+// checkExports resolves every subpath in every environment and checks the files it resolves to.
+func (c *checker) checkExports(exports interface{}, types bool) {
+	paths, problem := subpaths(exports)
+	if problem != nil {
+		c.report(problem.Path, problem.Message, nil)
+		return
+	}
+
+	for _, sp := range paths {
+		resolved := make(map[environment]resolution)
+		found := false
+		for _, env := range environments {
+			env := env
+			if env.types && !types {
+				continue
+			}
+
+			r, ok := resolveTarget(sp.target, sp.path, env.conditions())
+			if !ok || r.excluded {
+				continue
+			}
+			found = true
+			if !strings.HasPrefix(r.target, "./") {
+				c.report(r.path, fmt.Sprintf("target %q must start with \"./\"", r.target), nil)
+				continue
+			}
+			resolved[env] = r
+
+			if env.types {
+				c.checkTypes(sp, r, resolved, env)
+			} else {
+				c.checkTarget(sp, r, env)
+			}
+		}
+
+		// Subpaths only for other runtimes like deno or workers, or for custom conditions, may be intended
+		if !found && sp.target != nil {
+			c.warn(sp.path, fmt.Sprintf("no target resolves for import or require in node or browser, only with the conditions %s", strings.Join(targetConditions(sp.target), ", ")), nil)
+		}
+	}
+}
+
+// checkTarget checks that the files a runtime environment resolves to exist and are loaded in
+// the format they were built in.
+func (c *checker) checkTarget(sp subpath, r resolution, env environment) {
+	files := c.files(sp.key, r.target)
+	if len(files) == 0 {
+		c.report(r.path, fmt.Sprintf("%s does not exist", r.target), &env)
+		return
+	}
+
+	for _, file := range files {
+		c.checkFormat(r.path, file, &env)
+	}
+}
+
+// checkFormat checks that Node loads a file in the format it was built in, and that require does
+// not resolve to an ES module.
+func (c *checker) checkFormat(path, file string, env *environment) {
+	format := nodeFormat(file, c.packageType)
+	if format == "" {
+		return
+	}
+
+	if built, ok := c.formats[filepath.ToSlash(filepath.Clean(file))]; ok && built != config.PackageTypeTypes && built != format {
+		c.report(path, fmt.Sprintf("%s is built as %s, but Node loads it as %s %s", file, formatName(built), formatName(format), formatReason(file, c.packageType)), env)
+	}
+
+	// Bundlers resolving require for browsers accept ES modules
+	if env != nil && env.platform == "node" && env.mode == "require" && format == config.PackageTypeModule {
+		c.report(path, fmt.Sprintf("require resolves to %s, which is %s that only recent Node versions can require", file, formatName(format)), env)
+	}
+}
+
+// checkTypes checks that TypeScript finds declarations for the files the runtime environment
+// resolves to, and that they describe the format the files are loaded in.
+func (c *checker) checkTypes(sp subpath, r resolution, resolved map[environment]resolution, env environment) {
+	runtime, ok := resolved[env.runtime()]
+	if !ok {
+		return
+	}
+
+	declaration := r.target
+	if !isDeclaration(declaration) {
+		declaration = declarationFor(declaration)
+	}
+	if len(c.files(sp.key, declaration)) == 0 {
+		c.report(r.path, fmt.Sprintf("no declarations found for %s, expected %s", runtime.target, declaration), &env)
+		return
+	}
+
+	runtimeFormat := nodeFormat(runtime.target, c.packageType)
+	declarationFormat := declarationFormat(declaration, c.packageType)
+	if runtimeFormat != "" && runtimeFormat != declarationFormat {
+		c.report(r.path, fmt.Sprintf("%s describes %s, but %s is loaded as %s", declaration, formatName(declarationFormat), runtime.target, formatName(runtimeFormat)), &env)
+	}
+}
+
+// files returns the files a target refers to. Targets of subpath patterns, e.g. "./utils/*",
+// refer to every file matching their own pattern.
+func (c *checker) files(key, target string) []string {
+	if !strings.Contains(key, "*") || !strings.Contains(target, "*") {
+		if c.exists(target) {
+			return []string{target}
+		}
+		return nil
+	}
+
+	prefix, suffix, _ := strings.Cut(target, "*")
+	root := filepath.Join(c.dir, filepath.FromSlash(prefix[:strings.LastIndex(prefix, "/")+1]))
+
+	files := []string{}
+	_ = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(c.dir, path)
+		if err != nil {
+			return nil
+		}
+		file := "./" + filepath.ToSlash(rel)
+		if len(file) >= len(prefix)+len(suffix) && strings.HasPrefix(file, prefix) && strings.HasSuffix(file, suffix) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files
+}
+
+func (c *checker) exists(target string) bool {
+	info, err := os.Stat(filepath.Join(c.dir, filepath.FromSlash(target)))
+	return err == nil && !info.IsDir()
+}
+
+// hasTypes reports whether the package ships declarations, in which case TypeScript users
+// should find them for every subpath.
+func hasTypes(pkg *object) bool {
+	if _, ok := pkg.values["types"]; ok {
+		return true
+	}
+	if _, ok := pkg.values["typings"]; ok {
+		return true
+	}
+
+	var visit func(value interface{}) bool
+	visit = func(value interface{}) bool {
+		switch v := value.(type) {
+		case *object:
+			for _, key := range v.keys {
+				if key == "types" || visit(v.values[key]) {
+					return true
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				if visit(item) {
+					return true
+				}
+			}
+		case string:
+			return isDeclaration(v)
+		}
+		return false
+	}
+	return visit(pkg.values["exports"])
+}
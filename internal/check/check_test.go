@@ -0,0 +1,90 @@
+package check
+
+import (
+	"slices"
+	"squish/internal/config"
+	"squish/internal/testutil"
+	"testing"
+)
+
+func TestPackage(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		wantErrors   []string
+		wantWarnings []string
+	}{
+		{
+			name: "valid exports",
+			files: map[string]string{
+				"package.json":   `{"exports": {".": {"import": "./dist/index.mjs", "require": "./dist/index.cjs"}}}`,
+				"dist/index.mjs": ``,
+				"dist/index.cjs": ``,
+			},
+		},
+		{
+			name: "missing target",
+			files: map[string]string{
+				"package.json":   `{"exports": {".": {"import": "./dist/index.mjs", "require": "./dist/index.cjs"}}}`,
+				"dist/index.mjs": ``,
+			},
+			wantErrors: []string{`exports["."].require: ./dist/index.cjs does not exist (node require, browser require)`},
+		},
+		{
+			name: "subpath for deno and workers only",
+			files: map[string]string{
+				"package.json":    `{"exports": {".": {"import": "./dist/index.mjs"}, "./deno": {"deno": "./dist/deno.mjs", "worker": "./dist/worker.mjs"}}}`,
+				"dist/index.mjs":  ``,
+				"dist/deno.mjs":   ``,
+				"dist/worker.mjs": ``,
+			},
+			wantWarnings: []string{`exports["./deno"]: warning: no target resolves for import or require in node or browser, only with the conditions deno, worker`},
+		},
+		{
+			name: "require resolving to an ES module",
+			files: map[string]string{
+				"package.json":   `{"exports": {"browser": "./dist/index.mjs", "node": {"require": "./dist/index.mjs"}}}`,
+				"dist/index.mjs": ``,
+			},
+			wantErrors: []string{"exports.node.require: require resolves to ./dist/index.mjs, which is an ES module that only recent Node versions can require (node require)"},
+		},
+		{
+			name: "condition order",
+			files: map[string]string{
+				"package.json":    `{"exports": {"default": "./dist/index.js", "types": "./dist/index.d.ts"}}`,
+				"dist/index.js":   ``,
+				"dist/index.d.ts": ``,
+			},
+			wantErrors: []string{
+				`exports.default: "default" must be the last condition, the conditions after it are never used`,
+				`exports.types: "types" must be the first condition, TypeScript uses the first condition it matches`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			testutil.WriteFiles(t, dir, tt.files)
+			problems, err := Package(dir, map[string]config.PackageType{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errors, warnings := []string{}, []string{}
+			for _, problem := range problems {
+				if problem.Warning {
+					warnings = append(warnings, problem.String())
+				} else {
+					errors = append(errors, problem.String())
+				}
+			}
+			if !slices.Equal(errors, tt.wantErrors) {
+				t.Errorf("errors = %q, want %q", errors, tt.wantErrors)
+			}
+			if !slices.Equal(warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
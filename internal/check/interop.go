@@ -0,0 +1,313 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/evanw/esbuild/pkg/api"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"squish/internal/config"
+	"strings"
+)
+
+// ModuleExports are the named exports a consumer gets from an output file
+type ModuleExports struct {
+	File   string
+	Format config.PackageType
+	Names  []string
+	// Reexports are the modules a CommonJS file re-exports, which are not followed
+	Reexports []string
+}
+
+// SubpathInterop are the exports import and require consumers get from an exports subpath, nil
+// where the subpath does not resolve
+type SubpathInterop struct {
+	Path    string
+	Import  *ModuleExports
+	Require *ModuleExports
+}
+
+// Interop statically analyses the files the exports subpaths resolve to in Node, and reports the
+// named exports import and require consumers get. Problems are reported for CommonJS exports
+// Node's cjs-module-lexer cannot detect, which import consumers do not get, and for subpaths whose
+// import and require builds export different names.
+func Interop(dir string) ([]SubpathInterop, []Problem, error) {
+	pkg, packageType, err := readPackage(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &checker{dir: dir, packageType: packageType, index: make(map[string]*Problem)}
+	result := []SubpathInterop{}
+
+	exports, ok := pkg.values["exports"]
+	if !ok {
+		return result, nil, nil
+	}
+	paths, problem := subpaths(exports)
+	if problem != nil {
+		return result, []Problem{*problem}, nil
+	}
+
+	for _, sp := range paths {
+		// Patterns resolve to any number of files, which consumers import one at a time
+		if strings.Contains(sp.key, "*") {
+			continue
+		}
+
+		interop := SubpathInterop{Path: sp.path}
+		var importPath, requirePath string
+		for _, mode := range []string{"import", "require"} {
+			r, ok := resolveTarget(sp.target, sp.path, environment{platform: "node", mode: mode}.conditions())
+			if !ok || r.excluded || !strings.HasPrefix(r.target, "./") || !c.exists(r.target) {
+				continue
+			}
+			format := nodeFormat(r.target, packageType)
+			if format == "" {
+				continue
+			}
+
+			exports, err := c.moduleExports(r.target, format, mode)
+			if err != nil {
+				return nil, nil, err
+			}
+			if mode == "import" {
+				interop.Import, importPath = exports, r.path
+			} else {
+				interop.Require, requirePath = exports, r.path
+			}
+		}
+
+		c.checkInterop(interop, importPath, requirePath)
+		result = append(result, interop)
+	}
+
+	problems := make([]Problem, 0, len(c.problems))
+	for _, problem := range c.problems {
+		problems = append(problems, *problem)
+	}
+	return result, problems, nil
+}
+
+// moduleExports returns the exports of a file as seen by import or require consumers.
+func (c *checker) moduleExports(file string, format config.PackageType, mode string) (*ModuleExports, error) {
+	path := filepath.Join(c.dir, filepath.FromSlash(file))
+	exports := &ModuleExports{File: file, Format: format}
+
+	if format == config.PackageTypeModule {
+		names, err := esmExports(path)
+		if err != nil {
+			return nil, err
+		}
+		exports.Names = names
+		return exports, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lexer := lexCommonJS(string(data))
+	exports.Reexports = lexer.reexports
+	if mode == "import" {
+		// Node gives import consumers the names cjs-module-lexer detects, along with module.exports as default
+		exports.Names = sortedNames(append(lexer.names, "default"))
+	} else {
+		exports.Names = sortedNames(commonJSRuntimeExports(string(data), lexer))
+	}
+	return exports, nil
+}
+
+// checkInterop reports exports that import consumers of a CommonJS file do not get, and names
+// exported to only one of import and require.
+func (c *checker) checkInterop(interop SubpathInterop, importPath, requirePath string) {
+	if interop.Import != nil && interop.Import.Format == config.PackageTypeCommonJS && len(interop.Import.Reexports) == 0 {
+		data, err := os.ReadFile(filepath.Join(c.dir, filepath.FromSlash(interop.Import.File)))
+		if err == nil {
+			lexer := lexCommonJS(string(data))
+			detected := toSet(lexer.names)
+			for _, name := range sortedNames(commonJSRuntimeExports(string(data), lexer)) {
+				if !detected[name] && name != "default" {
+					c.report(importPath, fmt.Sprintf("cjs-module-lexer cannot detect the export %q of %s, so it is missing for import consumers", name, interop.Import.File), nil)
+				}
+			}
+		}
+	}
+
+	if interop.Import == nil || interop.Require == nil || interop.Import.File == interop.Require.File {
+		return
+	}
+	// Names re-exported from other modules are unknown, so the builds cannot be compared
+	if len(interop.Import.Reexports) > 0 || len(interop.Require.Reexports) > 0 {
+		return
+	}
+
+	importNames := toSet(interop.Import.Names)
+	requireNames := toSet(interop.Require.Names)
+
+	if interop.Import.Format == config.PackageTypeModule && interop.Require.Format == config.PackageTypeCommonJS && importNames["default"] && requireNames["default"] {
+		c.report(requirePath, fmt.Sprintf("require consumers have to use require(...).default to get the default export of %s", interop.Require.File), nil)
+	}
+
+	for _, name := range interop.Import.Names {
+		if !requireNames[name] && name != "default" {
+			c.report(requirePath, fmt.Sprintf("%s is exported by %s but not by %s", name, interop.Import.File, interop.Require.File), nil)
+		}
+	}
+	for _, name := range interop.Require.Names {
+		if !importNames[name] && name != "default" {
+			c.report(importPath, fmt.Sprintf("%s is exported by %s but not by %s", name, interop.Require.File, interop.Import.File), nil)
+		}
+	}
+}
+
+// esmExports returns the export names of an ES module, following its imports of local files like
+// chunks, as esbuild reports them.
+func esmExports(path string) ([]string, error) {
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{path},
+		Bundle:      true,
+		Packages:    api.PackagesExternal,
+		Platform:    api.PlatformNode,
+		Format:      api.FormatESModule,
+		Metafile:    true,
+		Write:       false,
+		Outdir:      filepath.Dir(path),
+		LogLevel:    api.LogLevelSilent,
+	})
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("failed to read exports of %s: %s", path, result.Errors[0].Text)
+	}
+
+	var meta struct {
+		Outputs map[string]struct {
+			Exports []string `json:"exports"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(result.Metafile), &meta); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, output := range meta.Outputs {
+		names = append(names, output.Exports...)
+	}
+	return sortedNames(names), nil
+}
+
+// commonJSLexer holds what cjs-module-lexer detects in a CommonJS file
+type commonJSLexer struct {
+	names     []string
+	reexports []string
+}
+
+var (
+	identifier             = `[A-Za-z_$][\w$]*`
+	exportsAssignPattern   = regexp.MustCompile(`(?:^|[^.\w$])(?:module\.)?exports\.(` + identifier + `)\s*=[^=]`)
+	exportsIndexPattern    = regexp.MustCompile(`(?:^|[^.\w$])(?:module\.)?exports\[\s*["']([^"']+)["']\s*\]\s*=[^=]`)
+	definePropertyPattern  = regexp.MustCompile(`Object\.defineProperty\(\s*(?:module\.)?exports\s*,\s*["']([^"']+)["']`)
+	moduleExportsObject    = regexp.MustCompile(`module\.exports\s*=\s*\{`)
+	moduleExportsRequire   = regexp.MustCompile(`module\.exports\s*=\s*require\(\s*["']([^"']+)["']\s*\)`)
+	exportStarPattern      = regexp.MustCompile(`__exportStar\(\s*require\(\s*["']([^"']+)["']\s*\)`)
+	objectRequireSpread    = regexp.MustCompile(`^\.\.\.\s*require\(\s*["']([^"']+)["']\s*\)$`)
+	objectShorthandPattern = regexp.MustCompile(`^(?:(` + identifier + `)|"([^"]*)"|'([^']*)')$`)
+	objectPropertyPattern  = regexp.MustCompile(`^(?:(` + identifier + `)|"([^"]*)"|'([^']*)')\s*:\s*` + identifier + `(?:\.` + identifier + `)*$`)
+	// getterObject matches the object of getters esbuild passes to __export, e.g.
+	// { add: () => add }, also when minified
+	getterObject     = `\{((?:\s*(?:` + identifier + `|"[^"]*")\s*:\s*\(\)\s*=>\s*[\w$.]+\s*,?)+)\s*\}`
+	getterKeyPattern = regexp.MustCompile(`(?:^|,)\s*(` + identifier + `|"[^"]*")\s*:`)
+	// toCommonJSPattern matches the assignment of the exports object to module.exports through
+	// esbuild's __toCommonJS helper
+	toCommonJSPattern = regexp.MustCompile(`module\.exports\s*=\s*` + identifier + `\(\s*(` + identifier + `)\s*\)`)
+)
+
+// lexCommonJS detects exports the way Node's cjs-module-lexer does: assignments to properties of
+// exports, Object.defineProperty on exports and object literals assigned to module.exports, such
+// as the annotation esbuild adds for Node.
+func lexCommonJS(code string) commonJSLexer {
+	lexer := commonJSLexer{}
+
+	for _, pattern := range []*regexp.Regexp{exportsAssignPattern, exportsIndexPattern, definePropertyPattern} {
+		for _, match := range pattern.FindAllStringSubmatch(code, -1) {
+			lexer.names = append(lexer.names, match[1])
+		}
+	}
+
+	for _, match := range moduleExportsRequire.FindAllStringSubmatch(code, -1) {
+		lexer.reexports = append(lexer.reexports, match[1])
+	}
+	for _, match := range exportStarPattern.FindAllStringSubmatch(code, -1) {
+		lexer.reexports = append(lexer.reexports, match[1])
+	}
+
+	for _, loc := range moduleExportsObject.FindAllStringIndex(code, -1) {
+		end := strings.Index(code[loc[1]:], "}")
+		if end < 0 {
+			continue
+		}
+		for _, property := range strings.Split(code[loc[1]:loc[1]+end], ",") {
+			property = strings.TrimSpace(property)
+			if property == "" {
+				continue
+			}
+			if match := objectRequireSpread.FindStringSubmatch(property); match != nil {
+				lexer.reexports = append(lexer.reexports, match[1])
+				continue
+			}
+			// esbuild annotates names that are not identifiers as bare strings
+			if match := objectShorthandPattern.FindStringSubmatch(property); match != nil {
+				lexer.names = append(lexer.names, match[1]+match[2]+match[3])
+				continue
+			}
+			match := objectPropertyPattern.FindStringSubmatch(property)
+			if match == nil {
+				// The lexer stops at the first property it does not understand
+				break
+			}
+			lexer.names = append(lexer.names, match[1]+match[2]+match[3])
+		}
+	}
+
+	lexer.names = sortedNames(lexer.names)
+	return lexer
+}
+
+// commonJSRuntimeExports returns the names a CommonJS file exports when it runs: the getters
+// esbuild's __export helper defines on the object assigned to module.exports in addition to what
+// the lexer detects. __export also builds namespace objects of imported modules, whose getters are
+// not exports of the file.
+func commonJSRuntimeExports(code string, lexer commonJSLexer) []string {
+	names := append([]string{}, lexer.names...)
+	for _, target := range toCommonJSPattern.FindAllStringSubmatch(code, -1) {
+		pattern := regexp.MustCompile(`(?:^|[^\w$.])` + identifier + `\(\s*` + regexp.QuoteMeta(target[1]) + `\s*,\s*` + getterObject)
+		for _, match := range pattern.FindAllStringSubmatch(code, -1) {
+			for _, key := range getterKeyPattern.FindAllStringSubmatch(match[1], -1) {
+				names = append(names, strings.Trim(key[1], `"`))
+			}
+		}
+	}
+	return sortedNames(names)
+}
+
+// sortedNames sorts and deduplicates export names, leaving out the __esModule marker
+func sortedNames(names []string) []string {
+	set := toSet(names)
+	delete(set, "__esModule")
+
+	sorted := make([]string, 0, len(set))
+	for name := range set {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
@@ -0,0 +1,175 @@
+package check
+
+import (
+	"github.com/evanw/esbuild/pkg/api"
+	"path/filepath"
+	"reflect"
+	"squish/internal/testutil"
+	"testing"
+)
+
+// buildCommonJS bundles src/index.ts of dir into CommonJS the way squish does for Node
+func buildCommonJS(t *testing.T, dir string, minify bool) string {
+	t.Helper()
+	result := api.Build(api.BuildOptions{
+		EntryPoints:       []string{filepath.Join(dir, "src", "index.ts")},
+		Bundle:            true,
+		Format:            api.FormatCommonJS,
+		Platform:          api.PlatformNode,
+		MinifyWhitespace:  minify,
+		MinifyIdentifiers: minify,
+		MinifySyntax:      minify,
+		LogLevel:          api.LogLevelSilent,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors[0].Text)
+	}
+	return string(result.OutputFiles[0].Contents)
+}
+
+func TestCommonJSExports(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantLexer   []string
+		wantRuntime []string
+	}{
+		{
+			name: "named and default exports",
+			files: map[string]string{
+				"src/index.ts": `export const a = 1; export function b() {} export default class C {}`,
+			},
+			wantLexer:   []string{"a", "b"},
+			wantRuntime: []string{"a", "b", "default"},
+		},
+		{
+			name: "namespace import re-exported as a name",
+			files: map[string]string{
+				"src/index.ts": `import * as util from "./util"; export { util }; export const c = 3;`,
+				"src/util.ts":  `export const a = 1; export function b() {}`,
+			},
+			wantLexer:   []string{"c", "util"},
+			wantRuntime: []string{"c", "util"},
+		},
+		{
+			name: "namespace import used internally",
+			files: map[string]string{
+				"src/index.ts": `import * as util from "./util"; export const keys = Object.keys(util);`,
+				"src/util.ts":  `export const a = 1; export const b = 2;`,
+			},
+			wantLexer:   []string{"keys"},
+			wantRuntime: []string{"keys"},
+		},
+		{
+			name: "string export names",
+			files: map[string]string{
+				"src/index.ts": `const a = 1; export { a as "a-b" };`,
+			},
+			wantLexer:   []string{"a-b"},
+			wantRuntime: []string{"a-b"},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, minify := range []bool{false, true} {
+			name := tt.name
+			if minify {
+				name += " minified"
+			}
+			t.Run(name, func(t *testing.T) {
+				dir := t.TempDir()
+				testutil.WriteFiles(t, dir, tt.files)
+				code := buildCommonJS(t, dir, minify)
+
+				lexer := lexCommonJS(code)
+				if !reflect.DeepEqual(lexer.names, tt.wantLexer) {
+					t.Errorf("lexCommonJS() = %q, want %q", lexer.names, tt.wantLexer)
+				}
+				if got := commonJSRuntimeExports(code, lexer); !reflect.DeepEqual(got, tt.wantRuntime) {
+					t.Errorf("commonJSRuntimeExports() = %q, want %q", got, tt.wantRuntime)
+				}
+			})
+		}
+	}
+}
+
+func TestLexCommonJS(t *testing.T) {
+	tests := []struct {
+		name          string
+		code          string
+		wantNames     []string
+		wantReexports []string
+	}{
+		{
+			name:      "exports assignments",
+			code:      `exports.a = 1; module.exports.b = 2; exports["c"] = 3; exports.d == 4;`,
+			wantNames: []string{"a", "b", "c"},
+		},
+		{
+			name:      "defineProperty",
+			code:      `Object.defineProperty(exports, "__esModule", { value: true }); Object.defineProperty(exports, "a", { get: () => a });`,
+			wantNames: []string{"a"},
+		},
+		{
+			name:          "object literal stops at unknown properties",
+			code:          `module.exports = { a, b: b, "c": c.d, ...require("./e"), f() {}, g };`,
+			wantNames:     []string{"a", "b", "c"},
+			wantReexports: []string{"./e"},
+		},
+		{
+			name:          "re-exports",
+			code:          `module.exports = require("./a"); __exportStar(require("./b"), exports);`,
+			wantNames:     []string{},
+			wantReexports: []string{"./a", "./b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lexer := lexCommonJS(tt.code)
+			if !reflect.DeepEqual(lexer.names, tt.wantNames) {
+				t.Errorf("names = %q, want %q", lexer.names, tt.wantNames)
+			}
+			if !reflect.DeepEqual(lexer.reexports, tt.wantReexports) {
+				t.Errorf("reexports = %q, want %q", lexer.reexports, tt.wantReexports)
+			}
+		})
+	}
+}
+
+// TestInteropNamespaceReexport checks that the exports of a module imported as a namespace are not
+// reported as missing from the ES module build.
+func TestInteropNamespaceReexport(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"package.json": `{"name": "pkg", "exports": {".": {"import": "./dist/index.mjs", "require": "./dist/index.cjs"}}}`,
+		"src/index.ts": `import * as util from "./util"; export { util }; export const c = 3;`,
+		"src/util.ts":  `export const a = 1; export function b() {}`,
+	})
+	for format, file := range map[api.Format]string{api.FormatESModule: "index.mjs", api.FormatCommonJS: "index.cjs"} {
+		result := api.Build(api.BuildOptions{
+			EntryPoints: []string{filepath.Join(dir, "src", "index.ts")},
+			Bundle:      true,
+			Format:      format,
+			Platform:    api.PlatformNode,
+			Outfile:     filepath.Join(dir, "dist", file),
+			Write:       true,
+			LogLevel:    api.LogLevelSilent,
+		})
+		if len(result.Errors) > 0 {
+			t.Fatal(result.Errors[0].Text)
+		}
+	}
+
+	interop, problems, err := Interop(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("unexpected problems %v", problems)
+	}
+	want := []string{"c", "util"}
+	if len(interop) != 1 || interop[0].Require == nil || !reflect.DeepEqual(interop[0].Require.Names, want) {
+		t.Errorf("require exports = %+v, want %q", interop, want)
+	}
+}
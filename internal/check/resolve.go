@@ -0,0 +1,213 @@
+package check
+
+import (
+	"fmt"
+	"sort"
+	"squish/internal/config"
+	"strings"
+)
+
+// environment is a combination of conditions a consumer resolves the export map with
+type environment struct {
+	platform string
+	mode     string
+	// types resolves the way TypeScript looks up declarations for the import or require
+	types bool
+}
+
+var environments = func() []environment {
+	envs := []environment{}
+	for _, platform := range []string{"node", "browser"} {
+		for _, mode := range []string{"import", "require"} {
+			envs = append(envs, environment{platform: platform, mode: mode})
+			envs = append(envs, environment{platform: platform, mode: mode, types: true})
+		}
+	}
+	return envs
+}()
+
+func (e environment) conditions() map[string]bool {
+	conditions := map[string]bool{e.platform: true, e.mode: true, "default": true}
+	if e.types {
+		conditions["types"] = true
+	}
+	return conditions
+}
+
+func (e environment) String() string {
+	if e.types {
+		return e.platform + " " + e.mode + " types"
+	}
+	return e.platform + " " + e.mode
+}
+
+// runtime returns the environment that loads the files whose declarations e looks up
+func (e environment) runtime() environment {
+	return environment{platform: e.platform, mode: e.mode}
+}
+
+// resolution is the target an environment resolves a subpath to
+type resolution struct {
+	target string
+	// path is the JSON path of the target in package.json
+	path string
+	// excluded is set when the target is null, which makes the subpath unavailable
+	excluded bool
+}
+
+// resolveTarget resolves an export target like Node's PACKAGE_TARGET_RESOLVE: the first condition
+// of an object that is in the set wins, and a condition whose target does not resolve is skipped.
+// It reports whether a target was found.
+func resolveTarget(target interface{}, path string, conditions map[string]bool) (resolution, bool) {
+	switch t := target.(type) {
+	case nil:
+		return resolution{path: path, excluded: true}, true
+	case string:
+		return resolution{target: t, path: path}, true
+	case *object:
+		for _, key := range t.keys {
+			if key != "default" && !conditions[key] {
+				continue
+			}
+			if r, ok := resolveTarget(t.values[key], config.JSONPath(path, key), conditions); ok {
+				return r, true
+			}
+		}
+	case []interface{}:
+		for i, value := range t {
+			if r, ok := resolveTarget(value, fmt.Sprintf("%s[%d]", path, i), conditions); ok {
+				return r, true
+			}
+		}
+	}
+	return resolution{}, false
+}
+
+// targetConditions returns the conditions used in an export target, other than the ones every
+// environment resolves with.
+func targetConditions(target interface{}) []string {
+	seen := make(map[string]bool)
+	var visit func(value interface{})
+	visit = func(value interface{}) {
+		switch v := value.(type) {
+		case *object:
+			for _, key := range v.keys {
+				seen[key] = true
+				visit(v.values[key])
+			}
+		case []interface{}:
+			for _, item := range v {
+				visit(item)
+			}
+		}
+	}
+	visit(target)
+
+	conditions := []string{}
+	for condition := range seen {
+		switch condition {
+		case "import", "require", "types", "default", "node", "browser":
+		default:
+			conditions = append(conditions, condition)
+		}
+	}
+	sort.Strings(conditions)
+	return conditions
+}
+
+// subpath is a key of the export map and its target
+type subpath struct {
+	key    string
+	path   string
+	target interface{}
+}
+
+// subpaths returns the subpaths of an export map. A map of conditions or a plain target is the
+// target of the "." subpath.
+func subpaths(exports interface{}) ([]subpath, *Problem) {
+	o, ok := exports.(*object)
+	if !ok {
+		return []subpath{{key: ".", path: "exports", target: exports}}, nil
+	}
+
+	subpathKeys := 0
+	for _, key := range o.keys {
+		if strings.HasPrefix(key, ".") {
+			subpathKeys++
+		}
+	}
+
+	switch subpathKeys {
+	case 0:
+		return []subpath{{key: ".", path: "exports", target: exports}}, nil
+	case len(o.keys):
+		result := make([]subpath, 0, len(o.keys))
+		for _, key := range o.keys {
+			result = append(result, subpath{key: key, path: config.JSONPath("exports", key), target: o.values[key]})
+		}
+		return result, nil
+	default:
+		return nil, &Problem{Path: "exports", Message: "subpaths and conditions cannot be mixed, keys must either all start with \".\" or none"}
+	}
+}
+
+// nodeFormat returns the format Node loads a file as, or "" for files that are not JavaScript.
+func nodeFormat(file string, packageType config.PackageType) config.PackageType {
+	switch {
+	case isDeclaration(file):
+		return ""
+	case strings.HasSuffix(file, ".mjs"):
+		return config.PackageTypeModule
+	case strings.HasSuffix(file, ".cjs"):
+		return config.PackageTypeCommonJS
+	case strings.HasSuffix(file, ".js"):
+		return packageType
+	default:
+		return ""
+	}
+}
+
+// declarationFormat returns the format TypeScript assumes for the module a declaration file describes.
+func declarationFormat(file string, packageType config.PackageType) config.PackageType {
+	switch {
+	case strings.HasSuffix(file, ".d.mts"):
+		return config.PackageTypeModule
+	case strings.HasSuffix(file, ".d.cts"):
+		return config.PackageTypeCommonJS
+	default:
+		return packageType
+	}
+}
+
+func isDeclaration(file string) bool {
+	return strings.HasSuffix(file, ".d.ts") || strings.HasSuffix(file, ".d.mts") || strings.HasSuffix(file, ".d.cts")
+}
+
+// declarationFor returns the declaration file TypeScript looks for next to a JavaScript file.
+func declarationFor(file string) string {
+	for _, ext := range [][2]string{{".mjs", ".d.mts"}, {".cjs", ".d.cts"}, {".js", ".d.ts"}} {
+		if strings.HasSuffix(file, ext[0]) {
+			return strings.TrimSuffix(file, ext[0]) + ext[1]
+		}
+	}
+	return file
+}
+
+// formatReason explains why Node loads a file in a format
+func formatReason(file string, packageType config.PackageType) string {
+	switch {
+	case strings.HasSuffix(file, ".mjs"), strings.HasSuffix(file, ".cjs"):
+		return "because of its extension"
+	case packageType == config.PackageTypeModule:
+		return "as package.json has \"type\": \"module\""
+	default:
+		return "as package.json has no \"type\": \"module\""
+	}
+}
+
+func formatName(format config.PackageType) string {
+	if format == config.PackageTypeModule {
+		return "an ES module"
+	}
+	return "CommonJS"
+}
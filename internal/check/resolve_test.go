@@ -0,0 +1,112 @@
+package check
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveTarget(t *testing.T) {
+	nodeImport := environment{platform: "node", mode: "import"}.conditions()
+	browserRequire := environment{platform: "browser", mode: "require"}.conditions()
+	nodeImportTypes := environment{platform: "node", mode: "import", types: true}.conditions()
+
+	tests := []struct {
+		name       string
+		target     string
+		conditions map[string]bool
+		want       resolution
+		wantOK     bool
+	}{
+		{
+			name:       "string",
+			target:     `"./dist/index.mjs"`,
+			conditions: nodeImport,
+			want:       resolution{target: "./dist/index.mjs", path: "exports"},
+			wantOK:     true,
+		},
+		{
+			name:       "null",
+			target:     `null`,
+			conditions: nodeImport,
+			want:       resolution{path: "exports", excluded: true},
+			wantOK:     true,
+		},
+		{
+			name:       "first matching condition",
+			target:     `{"require": "./dist/index.cjs", "import": "./dist/index.mjs", "default": "./dist/index.js"}`,
+			conditions: nodeImport,
+			want:       resolution{target: "./dist/index.mjs", path: "exports.import"},
+			wantOK:     true,
+		},
+		{
+			name:       "default",
+			target:     `{"import": "./dist/index.mjs", "default": "./dist/index.js"}`,
+			conditions: browserRequire,
+			want:       resolution{target: "./dist/index.js", path: "exports.default"},
+			wantOK:     true,
+		},
+		{
+			name:       "nested conditions",
+			target:     `{"browser": {"import": "./dist/browser.mjs"}, "node": {"types": "./dist/node.d.mts", "import": "./dist/node.mjs"}}`,
+			conditions: nodeImportTypes,
+			want:       resolution{target: "./dist/node.d.mts", path: "exports.node.types"},
+			wantOK:     true,
+		},
+		{
+			name:       "skips conditions without a target",
+			target:     `{"node": {"require": "./dist/node.cjs"}, "default": "./dist/index.mjs"}`,
+			conditions: nodeImport,
+			want:       resolution{target: "./dist/index.mjs", path: "exports.default"},
+			wantOK:     true,
+		},
+		{
+			name:       "unknown conditions",
+			target:     `{"deno": "./dist/deno.mjs", "worker": "./dist/worker.mjs"}`,
+			conditions: nodeImport,
+			wantOK:     false,
+		},
+		{
+			name:       "fallback array",
+			target:     `[{"worker": "./dist/worker.mjs"}, "./dist/index.mjs"]`,
+			conditions: nodeImport,
+			want:       resolution{target: "./dist/index.mjs", path: "exports[1]"},
+			wantOK:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := parseOrdered([]byte(tt.target))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := resolveTarget(target, "exports", tt.conditions)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("resolveTarget() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTargetConditions(t *testing.T) {
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{target: `"./dist/index.mjs"`, want: []string{}},
+		{target: `{"import": "./dist/index.mjs", "default": "./dist/index.js"}`, want: []string{}},
+		{target: `{"worker": {"import": "./a.mjs"}, "deno": "./b.mjs", "node": ["./c.js", {"development": "./d.js"}]}`, want: []string{"deno", "development", "worker"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			target, err := parseOrdered([]byte(tt.target))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := targetConditions(target); !slices.Equal(got, tt.want) {
+				t.Errorf("targetConditions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
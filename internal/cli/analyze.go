@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"squish/internal/analyze"
+	"squish/internal/config"
+	"squish/internal/utils"
+	"squish/pkg/esbuild"
+)
+
+var (
+	analyzeTop  int
+	analyzeHTML string
+)
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze [metafile...]",
+	Short: "Show what the bundled outputs consist of",
+	Long: `Show the largest modules and packages of every output and the packages included more than once.
+
+Without arguments the package is bundled with --metafile first and the metafiles of its entries
+are analyzed. Otherwise the given metafiles are analyzed, e.g. dist/*.meta.json.`,
+	RunE: runAnalyze,
+	// Errors are printed by main
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+func init() {
+	analyzeCmd.Flags().IntVar(&analyzeTop, "top", 10, "Number of modules and packages listed per output, 0 for all")
+	analyzeCmd.Flags().StringVar(&analyzeHTML, "html", "", "Write a treemap of the outputs to this HTML file")
+	rootCmd.AddCommand(analyzeCmd)
+}
+
+func runAnalyze(cmd *cobra.Command, args []string) error {
+	metafiles := args
+	if len(metafiles) == 0 {
+		var err error
+		if metafiles, err = bundleWithMetafiles(cmd); err != nil {
+			return err
+		}
+	}
+
+	report, err := analyze.ReadMetafiles(metafiles)
+	if err != nil {
+		return fmt.Errorf("error reading metafiles: %w", err)
+	}
+
+	report.Print(os.Stdout, analyzeTop)
+
+	if analyzeHTML != "" {
+		if err := report.WriteHTML(analyzeHTML); err != nil {
+			return fmt.Errorf("error writing HTML report: %w", err)
+		}
+		utils.Log("Treemap written to ", analyzeHTML)
+	}
+	return nil
+}
+
+// bundleWithMetafiles bundles the package with metafiles enabled and returns the metafiles of
+// its entries.
+func bundleWithMetafiles(cmd *cobra.Command) ([]string, error) {
+	bundlerConfig, entries, err := bundleOnce(cmd, func(c *esbuild.BundlerConfig) {
+		c.Metafile = true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metafiles := []string{}
+	for _, entry := range entries {
+		if entry.Format == config.PackageTypeTypes {
+			continue
+		}
+		outputPath := filepath.Join(bundlerConfig.DistDir, utils.GetDistRelativePath(entry.Output, bundlerConfig.DistDir))
+		metafiles = append(metafiles, esbuild.MetafilePath(outputPath))
+	}
+	return metafiles, nil
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"squish/internal/check"
+	"squish/internal/config"
+	"squish/internal/utils"
+	"strconv"
+	"strings"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Build the package and check that its exports resolve for consumers",
+	Long: `Build the package, then resolve every exports subpath the way Node and TypeScript do, for import
+and require in node and browser environments. The files it resolves to have to exist and be
+loaded in the format they were built in, "types" has to be the first condition and "default" the
+last. Problems are reported with the package.json path causing them.`,
+	Args: cobra.NoArgs,
+	RunE: runCheck,
+	// Errors are printed by main
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+var (
+	checkBuild   bool
+	checkInterop bool
+)
+
+func init() {
+	checkCmd.Flags().BoolVar(&checkBuild, "build", true, "Build the package before checking, use --build=false to check an existing build")
+	checkCmd.Flags().BoolVar(&checkInterop, "interop", false, "Analyse the named exports import and require consumers get from every subpath, and report CommonJS exports Node cannot detect and differences between the builds")
+	rootCmd.AddCommand(checkCmd)
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	// Without a build the formats files were built in are unknown, so only their extensions are checked
+	formats := make(map[string]config.PackageType)
+	if checkBuild {
+		_, entries, err := bundleOnce(cmd, nil)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			formats[entry.Output] = entry.Format
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current working directory: %w", err)
+	}
+
+	problems, err := check.Package(cwd, formats)
+	if err != nil {
+		return err
+	}
+
+	if checkInterop {
+		interop, interopProblems, err := check.Interop(cwd)
+		if err != nil {
+			return err
+		}
+		printInterop(interop)
+		problems = append(problems, interopProblems...)
+	}
+
+	if len(problems) == 0 {
+		utils.Log("No problems found in package.json")
+		return nil
+	}
+
+	failures := 0
+	for _, problem := range problems {
+		fmt.Println(problem)
+		if !problem.Warning {
+			failures++
+		}
+	}
+	switch failures {
+	case 0:
+		utils.Log("Found only warnings in package.json")
+		return nil
+	case 1:
+		return fmt.Errorf("found 1 problem in package.json")
+	default:
+		return fmt.Errorf("found %d problems in package.json", failures)
+	}
+}
+
+// printInterop prints the named exports import and require consumers get from every subpath.
+func printInterop(interop []check.SubpathInterop) {
+	for _, subpath := range interop {
+		fmt.Println(subpath.Path)
+		printModuleExports("import", subpath.Import)
+		printModuleExports("require", subpath.Require)
+	}
+	fmt.Println()
+}
+
+func printModuleExports(mode string, exports *check.ModuleExports) {
+	if exports == nil {
+		fmt.Printf("  %-8s (does not resolve)\n", mode)
+		return
+	}
+
+	names := strings.Join(exports.Names, ", ")
+	if names == "" {
+		names = "(no named exports)"
+	}
+	for _, reexport := range exports.Reexports {
+		names += ", ...require(" + strconv.Quote(reexport) + ")"
+	}
+	fmt.Printf("  %-8s %s (%s): %s\n", mode, exports.File, exports.Format, names)
+}
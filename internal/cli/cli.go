@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"fmt"
 	"github.com/spf13/cobra"
+	"io"
 	"os"
+	"path/filepath"
 	"squish/internal/config"
 	"squish/internal/utils"
 	"squish/internal/watcher"
@@ -23,6 +26,15 @@ var (
 	sourcemap        string
 	cleanDist        bool
 	bundle           bool
+	concurrency      int
+	platform         string
+	browserBuiltins  string
+	metafile         bool
+	unbundled        bool
+	cjsInterop       bool
+	customConditions []string
+	onSuccess        string
+	onSuccessTimeout time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -36,20 +48,42 @@ func Execute() error {
 }
 
 func init() {
-	rootCmd.Flags().StringVar(&srcFlag, "src", "./src", "Source directory")
-	rootCmd.Flags().StringVar(&distFlag, "dist", "./dist", "Output directory")
-	rootCmd.Flags().BoolVar(&minify, "minify", false, "Minify output")
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&srcFlag, "src", "./src", "Source directory")
+	flags.StringVar(&distFlag, "dist", "./dist", "Output directory")
+	flags.BoolVar(&minify, "minify", false, "Minify output")
 	rootCmd.Flags().BoolVarP(&watchMode, "watch", "w", false, "Watch mode")
-	rootCmd.Flags().StringSliceVar(&target, "target", []string{"es2022"}, "Environments to support")
-	rootCmd.Flags().StringVar(&tsconfigPath, "tsconfig", "", "Custom tsconfig.json file path")
-	rootCmd.Flags().StringSliceVar(&env, "env", []string{}, "Compile-time environment variables (e.g., --env NODE_ENV=production)")
-	rootCmd.Flags().StringSliceVar(&exportConditions, "export-condition", []string{}, "Export conditions for resolving dependency export and import maps")
-	rootCmd.Flags().StringVar(&sourcemap, "sourcemap", "", "Sourcemap generation. Provide 'inline' for inline sourcemap")
-	rootCmd.Flags().BoolVar(&cleanDist, "clean-dist", false, "Clean dist before bundling")
-	rootCmd.Flags().BoolVar(&bundle, "bundle", true, "Bundle all dependencies")
+	rootCmd.Flags().StringVar(&onSuccess, "on-success", "", "Command to run after the first successful build in watch mode, restarted after every successful rebuild")
+	rootCmd.Flags().DurationVar(&onSuccessTimeout, "on-success-timeout", 5*time.Second, "Time the --on-success command gets to exit after SIGTERM before it is killed")
+	flags.StringSliceVar(&target, "target", []string{}, "Environments to support, e.g. es2022, node18 or chrome100 (default: engines.node and browserslist from package.json, otherwise es2022)")
+	flags.StringVar(&tsconfigPath, "tsconfig", "", "Custom tsconfig.json file path")
+	flags.StringSliceVar(&env, "env", []string{}, "Compile-time environment variables (e.g., --env NODE_ENV=production)")
+	flags.StringSliceVar(&exportConditions, "export-condition", []string{}, "Export conditions for resolving dependency export and import maps")
+	flags.StringSliceVar(&customConditions, "custom-condition", []string{}, "Custom export conditions used in the package.json exports, which are warned about when not declared")
+	flags.StringVar(&sourcemap, "sourcemap", "", "Sourcemap generation. Provide 'inline' for inline sourcemap")
+	flags.BoolVar(&cleanDist, "clean-dist", false, "Clean dist before bundling")
+	flags.BoolVar(&bundle, "bundle", true, "Bundle all dependencies")
+	flags.StringVar(&platform, "platform", "node", "Default platform for entries whose export conditions do not imply one (node, browser, neutral)")
+	flags.StringVar(&browserBuiltins, "browser-builtins", "error", "Node builtin imports in browser builds without a polyfill: 'error' to fail or 'stub' to replace them with empty modules")
+	flags.IntVar(&concurrency, "concurrency", 0, "Maximum number of entries, or with --workspaces of packages, built in parallel (default: number of CPUs)")
+	flags.BoolVar(&metafile, "metafile", false, "Write the esbuild metafile of every entry next to its output, as <output>.meta.json")
+	flags.BoolVar(&cjsInterop, "cjs-interop", false, "Make the default export of CommonJS outputs their module.exports, so require consumers do not need .default")
+	flags.BoolVar(&unbundled, "unbundled", false, "Transpile every source file reachable from the entries to its own file in dist, keeping the directory structure, instead of bundling")
 }
 
 func run(cmd *cobra.Command, args []string) {
+	if workspacesMode {
+		if err := runWorkspaces(cmd); err != nil {
+			utils.Log("Error building workspaces: ", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(filters) > 0 {
+		utils.Log("Error: --filter requires --workspaces")
+		os.Exit(1)
+	}
+
 	startTime := time.Now()
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -63,31 +97,50 @@ func run(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	utils.Log("Bundling package:", pkg.Name)
+	if onSuccess != "" && !watchMode {
+		utils.Log("Error: --on-success requires --watch")
+		os.Exit(1)
+	}
 
-	bundlerConfig := &esbuild.BundlerConfig{
-		SrcDir:           srcFlag,
-		DistDir:          distFlag,
-		Minify:           minify,
-		Target:           target,
-		TsconfigPath:     tsconfigPath,
-		Env:              parseEnvFlags(env),
-		ExportConditions: exportConditions,
-		Sourcemap:        sourcemap,
-		CleanDist:        cleanDist,
-		Bundle:           bundle,
+	options, overrides, _, err := resolveConfig(cmd, cwd, pkg)
+	if err != nil {
+		utils.Log("Error reading configuration:", err)
+		os.Exit(1)
 	}
 
+	utils.Log("Bundling package:", pkg.Name)
+
+	bundlerConfig := newBundlerConfig(cwd, options, overrides)
 	bundler := esbuild.NewBundler(bundlerConfig, pkg)
 
 	if watchMode {
-		w := watcher.NewWatcher(bundler, srcFlag)
+		reload := func() ([]string, error) {
+			pkg, err := config.ReadPackageJSON(cwd)
+			if err != nil {
+				return nil, fmt.Errorf("error reading package.json: %w", err)
+			}
+			options, overrides, _, err := resolveConfig(cmd, cwd, pkg)
+			if err != nil {
+				return nil, err
+			}
+			bundler.Reconfigure(newBundlerConfig(cwd, options, overrides), pkg)
+			return configFiles(bundler), nil
+		}
+
+		var process *watcher.Process
+		if onSuccess != "" {
+			process = watcher.NewProcess(onSuccess, onSuccessTimeout)
+		}
+
+		w := watcher.NewWatcher(bundler, configFiles(bundler), reload, process)
 		if err := w.Watch(); err != nil {
 			utils.Log("Error watching:", err)
 			os.Exit(1)
 		}
 	} else {
-		if err := bundler.Bundle(); err != nil {
+		err := bundler.Bundle()
+		bundler.Dispose()
+		if err != nil {
 			utils.Log("Error bundling:", err)
 			os.Exit(1)
 		}
@@ -103,3 +156,234 @@ func parseEnvFlags(envFlags []string) map[string]string {
 	}
 	return envMap
 }
+
+// resolveConfig combines the flags set on the command line, the configuration file and the flag
+// defaults, in that order of precedence. The returned options have every option set.
+func resolveConfig(cmd *cobra.Command, cwd string, pkg *config.PackageJSON) (config.Options, []config.Override, *config.SquishConfig, error) {
+	squishConfig, err := config.LoadSquishConfig(cwd, pkg)
+	if err != nil {
+		return config.Options{}, nil, nil, err
+	}
+
+	options, overrides := squishConfig.Resolve(flagOptions(cmd, true))
+	defaults := flagOptions(cmd, false)
+	tsconfigDefaults, err := tsconfigOptions(os.Stdout, cwd, pkg, defaults, options)
+	if err != nil {
+		return config.Options{}, nil, nil, err
+	}
+	return defaults.Merge(tsconfigDefaults).Merge(options), overrides, squishConfig, nil
+}
+
+// tsconfigOptions returns the source and dist directories set by rootDir and outDir in the
+// tsconfig, which are used when options, set by the flags and the configuration, do not set them.
+// rootDir is only used when it is a directory below cwd, and outDir only when every entry of the
+// package is built into it, as the sources of entries are found by their path in dist. Ignored
+// values are logged to w.
+func tsconfigOptions(w io.Writer, cwd string, pkg *config.PackageJSON, defaults, options config.Options) (config.Options, error) {
+	if options.Src != nil && options.Dist != nil {
+		return config.Options{}, nil
+	}
+	merged := defaults.Merge(options)
+
+	tsconfigPath := *merged.Tsconfig
+	if tsconfigPath == "" {
+		tsconfigPath = filepath.Join(cwd, "tsconfig.json")
+		if !utils.FileExists(tsconfigPath) {
+			return config.Options{}, nil
+		}
+	}
+
+	tsconfig, err := utils.ReadTsconfig(tsconfigPath)
+	if err != nil {
+		return config.Options{}, fmt.Errorf("error reading tsconfig: %w", err)
+	}
+
+	result := config.Options{}
+	if src := relativeDir(cwd, tsconfig.RootDir); src != "" && options.Src == nil {
+		if src == "." || strings.HasPrefix(src, "..") {
+			utils.LogTo(w, "Ignoring rootDir ", src, " of the tsconfig, using ", *merged.Src, " as the source directory")
+		} else {
+			result.Src = &src
+		}
+	}
+	if dist := relativeDir(cwd, tsconfig.OutDir); dist != "" && options.Dist == nil {
+		entries, err := pkg.GetExportEntries()
+		if err != nil {
+			return config.Options{}, err
+		}
+		if containsEntries(cwd, tsconfig.OutDir, entries) {
+			result.Dist = &dist
+		} else {
+			utils.LogTo(w, "Ignoring outDir ", dist, " of the tsconfig, the package.json entries are not built into it, using ", *merged.Dist, " as the output directory")
+		}
+	}
+	return result, nil
+}
+
+// containsEntries reports whether the outputs of all entries are in dir.
+func containsEntries(cwd, dir string, entries []config.ExportEntry) bool {
+	if len(entries) == 0 {
+		return false
+	}
+	for _, entry := range entries {
+		rel, err := filepath.Rel(dir, filepath.Join(cwd, entry.OutputPath))
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return false
+		}
+	}
+	return true
+}
+
+// relativeDir returns a directory relative to cwd in the form of the --src and --dist flags, e.g.
+// "./lib", or "" when dir is not set.
+func relativeDir(cwd, dir string) string {
+	if dir == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(cwd, dir)
+	if err != nil {
+		return ""
+	}
+	if rel == "." || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return "./" + filepath.ToSlash(rel)
+}
+
+// flagOptions returns the options given by flags, either all of them including defaults or only
+// the ones set on the command line.
+func flagOptions(cmd *cobra.Command, changedOnly bool) config.Options {
+	flags := cmd.Flags()
+	isSet := func(name string) bool {
+		return !changedOnly || flags.Changed(name)
+	}
+
+	options := config.Options{}
+	if isSet("src") {
+		options.Src = &srcFlag
+	}
+	if isSet("dist") {
+		options.Dist = &distFlag
+	}
+	if isSet("tsconfig") {
+		options.Tsconfig = &tsconfigPath
+	}
+	if isSet("clean-dist") {
+		options.CleanDist = &cleanDist
+	}
+	if isSet("bundle") {
+		options.Bundle = &bundle
+	}
+	if isSet("concurrency") {
+		options.Concurrency = &concurrency
+	}
+	if isSet("metafile") {
+		options.Metafile = &metafile
+	}
+	if isSet("custom-condition") {
+		options.CustomConditions = customConditions
+	}
+	if isSet("unbundled") {
+		options.Unbundled = &unbundled
+	}
+	if isSet("minify") {
+		options.Minify = &minify
+	}
+	if isSet("target") && len(target) > 0 {
+		options.Target = target
+	}
+	if isSet("env") {
+		options.Env = parseEnvFlags(env)
+	}
+	if isSet("export-condition") && len(exportConditions) > 0 {
+		options.ExportConditions = exportConditions
+	}
+	if isSet("sourcemap") {
+		options.Sourcemap = &sourcemap
+	}
+	if isSet("platform") {
+		options.Platform = &platform
+	}
+	if isSet("browser-builtins") {
+		options.BrowserBuiltins = &browserBuiltins
+	}
+	if isSet("cjs-interop") {
+		options.CjsInterop = &cjsInterop
+	}
+	return options
+}
+
+// newBundlerConfig creates the bundler configuration from fully resolved options.
+func newBundlerConfig(cwd string, options config.Options, overrides []config.Override) *esbuild.BundlerConfig {
+	return &esbuild.BundlerConfig{
+		PackageDir:       cwd,
+		SrcDir:           *options.Src,
+		DistDir:          *options.Dist,
+		Minify:           *options.Minify,
+		Target:           options.Target,
+		TsconfigPath:     *options.Tsconfig,
+		Env:              options.Env,
+		ExportConditions: options.ExportConditions,
+		Sourcemap:        *options.Sourcemap,
+		CleanDist:        *options.CleanDist,
+		Bundle:           *options.Bundle,
+		Concurrency:      *options.Concurrency,
+		Platform:         *options.Platform,
+		BrowserBuiltins:  *options.BrowserBuiltins,
+		CjsInterop:       *options.CjsInterop,
+		Overrides:        overrides,
+		Metafile:         *options.Metafile,
+		Budgets:          options.Budgets,
+		Unbundled:        *options.Unbundled,
+		CustomConditions: options.CustomConditions,
+	}
+}
+
+// bundleOnce bundles the package with the resolved configuration, after adjusting it, and returns
+// the configuration and the entries that were built.
+func bundleOnce(cmd *cobra.Command, adjust func(*esbuild.BundlerConfig)) (*esbuild.BundlerConfig, []esbuild.ResolvedEntry, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting current working directory: %w", err)
+	}
+
+	pkg, err := config.ReadPackageJSON(cwd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading package.json: %w", err)
+	}
+
+	options, overrides, _, err := resolveConfig(cmd, cwd, pkg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading configuration: %w", err)
+	}
+
+	bundlerConfig := newBundlerConfig(cwd, options, overrides)
+	if adjust != nil {
+		adjust(bundlerConfig)
+	}
+	bundler := esbuild.NewBundler(bundlerConfig, pkg)
+
+	utils.Log("Bundling package:", pkg.Name)
+	err = bundler.Bundle()
+	bundler.Dispose()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error bundling: %w", err)
+	}
+
+	entries, err := bundler.ResolveEntries()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error resolving entries: %w", err)
+	}
+	return bundlerConfig, entries, nil
+}
+
+// configFiles returns the files the configuration is read from, which are watched in watch mode.
+func configFiles(bundler *esbuild.Bundler) []string {
+	files := []string{"package.json", config.ConfigFileName}
+
+	tsconfigFiles, err := bundler.TsconfigFiles()
+	if err != nil {
+		utils.Log("Error reading tsconfig:", err)
+	}
+	return append(files, tsconfigFiles...)
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"squish/internal/config"
+	"squish/internal/testutil"
+	"squish/internal/utils"
+	"strings"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestTsconfigOptions(t *testing.T) {
+	dist := "./build"
+	tests := []struct {
+		name     string
+		files    map[string]string
+		options  config.Options
+		wantSrc  string
+		wantDist string
+		wantLog  []string
+	}{
+		{
+			name: "rootDir and outDir containing the entries",
+			files: map[string]string{
+				"package.json":  `{"name": "pkg", "exports": {".": {"types": "./out/index.d.ts", "default": "./out/index.js"}}}`,
+				"tsconfig.json": `{"compilerOptions": {"rootDir": "./source", "outDir": "./out"}}`,
+			},
+			wantSrc:  "./source",
+			wantDist: "./out",
+		},
+		{
+			name: "rootDir of the package and outDir for declarations only",
+			files: map[string]string{
+				"package.json":  `{"name": "pkg", "exports": {".": "./dist/index.mjs"}}`,
+				"tsconfig.json": `{"compilerOptions": {"rootDir": ".", "outDir": "lib/types"}}`,
+			},
+			wantLog: []string{"Ignoring rootDir . of the tsconfig", "Ignoring outDir ./lib/types of the tsconfig"},
+		},
+		{
+			name: "rootDir outside of the package",
+			files: map[string]string{
+				"package.json":  `{"name": "pkg", "main": "./dist/index.js"}`,
+				"tsconfig.json": `{"compilerOptions": {"rootDir": ".."}}`,
+			},
+			wantLog: []string{"Ignoring rootDir .. of the tsconfig"},
+		},
+		{
+			name: "outDir without entries",
+			files: map[string]string{
+				"package.json":  `{"name": "pkg"}`,
+				"tsconfig.json": `{"compilerOptions": {"outDir": "dist"}}`,
+			},
+			wantLog: []string{"Ignoring outDir ./dist of the tsconfig"},
+		},
+		{
+			name: "dist set by the configuration",
+			files: map[string]string{
+				"package.json":  `{"name": "pkg", "main": "./build/index.js"}`,
+				"tsconfig.json": `{"compilerOptions": {"rootDir": "./lib", "outDir": "lib/types"}}`,
+			},
+			options: config.Options{Dist: &dist},
+			wantSrc: "./lib",
+		},
+		{
+			name: "extended tsconfig",
+			files: map[string]string{
+				"package.json":       `{"name": "pkg", "main": "./out/index.js"}`,
+				"tsconfig.base.json": `{"compilerOptions": {"outDir": "./out"}}`,
+				"tsconfig.json":      `{"extends": "./tsconfig.base.json"}`,
+			},
+			wantDist: "./out",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			testutil.WriteFiles(t, dir, tt.files)
+			pkg, err := config.ReadPackageJSON(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var log bytes.Buffer
+			result, err := tsconfigOptions(&log, dir, pkg, flagOptions(rootCmd, false), tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := stringOption(result.Src); got != tt.wantSrc {
+				t.Errorf("src = %q, want %q", got, tt.wantSrc)
+			}
+			if got := stringOption(result.Dist); got != tt.wantDist {
+				t.Errorf("dist = %q, want %q", got, tt.wantDist)
+			}
+			for _, want := range tt.wantLog {
+				if !strings.Contains(log.String(), want) {
+					t.Errorf("log %q does not contain %q", log.String(), want)
+				}
+			}
+			if len(tt.wantLog) == 0 && log.Len() > 0 {
+				t.Errorf("unexpected log %q", log.String())
+			}
+		})
+	}
+}
+
+// TestTsconfigOptionsKeepsDefaults resolves the entries of a package with a tsconfig whose rootDir
+// and outDir do not describe where squish builds from and to.
+func TestTsconfigOptionsKeepsDefaults(t *testing.T) {
+	dir := tempDir(t)
+	testutil.WriteFiles(t, dir, map[string]string{
+		"package.json":  `{"name": "pkg", "exports": {".": "./dist/index.mjs"}}`,
+		"tsconfig.json": `{"compilerOptions": {"rootDir": ".", "outDir": "lib/types"}}`,
+		"src/index.ts":  `export const a = 1;`,
+	})
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	pkg, err := config.ReadPackageJSON(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := flagOptions(rootCmd, false)
+	tsconfigDefaults, err := tsconfigOptions(&bytes.Buffer{}, dir, pkg, defaults, config.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := defaults.Merge(tsconfigDefaults)
+
+	entries, err := pkg.GetExportEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := utils.GetSourcePath(entries[0], *options.Src, *options.Dist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.ToSlash(source.Input) != "src/index.ts" {
+		t.Errorf("source = %q, want src/index.ts", source.Input)
+	}
+}
+
+func stringOption(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"squish/internal/config"
+	"squish/pkg/esbuild"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the squish configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the resolved configuration and the options each entry is built with",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigPrint,
+	// Errors are printed by main
+	SilenceErrors: true,
+	SilenceUsage:  true,
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON schema of " + config.ConfigFileName,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Stdout.Write(config.Schema)
+	},
+}
+
+// resolvedConfig is the output of `squish config print`
+type resolvedConfig struct {
+	Source    string                  `json:"source,omitempty"`
+	Options   config.Options          `json:"options"`
+	Overrides []config.Override       `json:"overrides,omitempty"`
+	Entries   []esbuild.ResolvedEntry `json:"entries"`
+}
+
+func init() {
+	configCmd.AddCommand(configPrintCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigPrint(cmd *cobra.Command, args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current working directory: %w", err)
+	}
+
+	pkg, err := config.ReadPackageJSON(cwd)
+	if err != nil {
+		return fmt.Errorf("error reading package.json: %w", err)
+	}
+
+	options, overrides, squishConfig, err := resolveConfig(cmd, cwd, pkg)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %w", err)
+	}
+
+	entries, err := esbuild.NewBundler(newBundlerConfig(cwd, options, overrides), pkg).ResolveEntries()
+	if err != nil {
+		return fmt.Errorf("error resolving entries: %w", err)
+	}
+
+	data, err := json.MarshalIndent(resolvedConfig{
+		Source:    squishConfig.Source,
+		Options:   options,
+		Overrides: overrides,
+		Entries:   entries,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
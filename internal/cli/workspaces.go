@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"squish/internal/utils"
+	"squish/internal/workspace"
+	"strings"
+	"time"
+)
+
+var (
+	workspacesMode bool
+	filters        []string
+)
+
+// workspaceFlags are the flags of the root command that are not passed on to the builds of the
+// workspace packages. Concurrency limits how many packages are built at once, each building its
+// entries one at a time.
+var workspaceFlags = map[string]bool{
+	"workspaces":  true,
+	"filter":      true,
+	"concurrency": true,
+}
+
+// pathFlags are the flags holding a path relative to the working directory, which are made
+// absolute as the packages are built in their own directories
+var pathFlags = map[string]bool{
+	"tsconfig": true,
+}
+
+func init() {
+	rootCmd.Flags().BoolVar(&workspacesMode, "workspaces", false, "Build every package of the workspaces in package.json or pnpm-workspace.yaml, each after the packages it depends on")
+	rootCmd.Flags().StringSliceVar(&filters, "filter", []string{}, "With --workspaces, only build the packages matching a name, a name glob or a \"./\" directory glob. \"name...\" adds its dependencies, \"...name\" its dependents")
+}
+
+// runWorkspaces builds the packages of the workspace rooted in the current directory. Every
+// package is built by its own squish process, running in the package directory with the flags
+// set on the command line.
+func runWorkspaces(cmd *cobra.Command) error {
+	startTime := time.Now()
+	if watchMode {
+		return fmt.Errorf("--workspaces does not support --watch")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("error getting current working directory: %w", err)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error finding the squish executable: %w", err)
+	}
+
+	ws, err := workspace.Discover(cwd)
+	if err != nil {
+		return fmt.Errorf("error reading workspaces: %w", err)
+	}
+	packages, err := ws.Filter(filters)
+	if err != nil {
+		return err
+	}
+	if len(packages) == 0 {
+		return fmt.Errorf("no workspace packages found")
+	}
+
+	names := make([]string, 0, len(packages))
+	for _, p := range workspace.Order(packages) {
+		names = append(names, p.Name)
+	}
+	utils.Log("Building workspace packages: ", strings.Join(names, ", "))
+
+	args := forwardedFlags(cmd, cwd)
+	err = workspace.Build(packages, concurrency, func(p *workspace.Package, w io.Writer) error {
+		entries, err := p.JSON.GetExportEntries()
+		if err != nil {
+			return fmt.Errorf("error reading entries: %w", err)
+		}
+		if len(entries) == 0 {
+			utils.LogTo(w, "Skipping ", p.Name, ", its package.json has no entry points")
+			return nil
+		}
+
+		build := exec.Command(executable, args...)
+		build.Dir = p.Dir
+		build.Stdout = w
+		build.Stderr = w
+		if err := build.Run(); err != nil {
+			rel, _ := filepath.Rel(cwd, p.Dir)
+			return fmt.Errorf("build in %s failed: %w", rel, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	utils.Log("Workspace packages built successfully in ", time.Since(startTime))
+	return nil
+}
+
+// forwardedFlags returns the flags set on the command line as arguments for the builds of the
+// workspace packages.
+func forwardedFlags(cmd *cobra.Command, cwd string) []string {
+	args := []string{"--concurrency=1"}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if workspaceFlags[flag.Name] {
+			return
+		}
+		if slice, ok := flag.Value.(interface{ GetSlice() []string }); ok {
+			for _, value := range slice.GetSlice() {
+				args = append(args, "--"+flag.Name+"="+value)
+			}
+			return
+		}
+		value := flag.Value.String()
+		if pathFlags[flag.Name] && value != "" && !filepath.IsAbs(value) {
+			value = filepath.Join(cwd, value)
+		}
+		args = append(args, "--"+flag.Name+"="+value)
+	})
+	return args
+}
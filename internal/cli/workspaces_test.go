@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestForwardedFlags(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "repo")
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "no flags",
+			want: []string{"--concurrency=1"},
+		},
+		{
+			name: "workspace flags and concurrency are not forwarded",
+			args: []string{"--workspaces", "--filter=@acme/*", "--concurrency=4", "--minify"},
+			want: []string{"--concurrency=1", "--minify=true"},
+		},
+		{
+			name: "slice flags are repeated",
+			args: []string{"--target=node18,chrome100", "--src=./lib"},
+			want: []string{"--concurrency=1", "--src=./lib", "--target=node18", "--target=chrome100"},
+		},
+		{
+			name: "relative tsconfig resolved against the root",
+			args: []string{"--tsconfig=tsconfig.build.json"},
+			want: []string{"--concurrency=1", "--tsconfig=" + filepath.Join(root, "tsconfig.build.json")},
+		},
+		{
+			name: "absolute tsconfig kept",
+			args: []string{"--tsconfig=" + filepath.Join(root, "configs", "tsconfig.json")},
+			want: []string{"--concurrency=1", "--tsconfig=" + filepath.Join(root, "configs", "tsconfig.json")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			flags := cmd.Flags()
+			flags.Bool("workspaces", false, "")
+			flags.StringSlice("filter", nil, "")
+			flags.Int("concurrency", 0, "")
+			flags.Bool("minify", false, "")
+			flags.String("src", "./src", "")
+			flags.String("tsconfig", "", "")
+			flags.StringSlice("target", nil, "")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := forwardedFlags(cmd, root); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("forwardedFlags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
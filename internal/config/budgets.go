@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Compression is the compression a size budget is measured with
+type Compression string
+
+const (
+	CompressionNone   Compression = ""
+	CompressionGzip   Compression = "gzip"
+	CompressionBrotli Compression = "brotli"
+)
+
+// Budget is the size an output file may not exceed
+type Budget struct {
+	// Output is the output file or glob the budget applies to, e.g. dist/index.mjs or dist/**/*.js
+	Output      string
+	Limit       int
+	Compression Compression
+}
+
+var budgetUnits = map[string]int{
+	"b":  1,
+	"kb": 1024,
+	"mb": 1024 * 1024,
+}
+
+var budgetCompressions = map[string]Compression{
+	"":       CompressionNone,
+	"raw":    CompressionNone,
+	"gz":     CompressionGzip,
+	"gzip":   CompressionGzip,
+	"br":     CompressionBrotli,
+	"brotli": CompressionBrotli,
+}
+
+// ParseBudget parses a budget like "12kb", "12 KB gz" or "1.5mb brotli". Sizes without a unit are
+// in bytes and budgets without a compression apply to the raw size.
+func ParseBudget(output, budget string) (Budget, error) {
+	fields := strings.Fields(strings.ToLower(budget))
+	if len(fields) == 0 {
+		return Budget{}, fmt.Errorf("budget for %s: empty budget", output)
+	}
+
+	size := fields[0]
+	rest := fields[1:]
+	// The unit can be separated from the number, as in "12 kb gz"
+	if len(rest) > 0 && budgetUnits[rest[0]] != 0 {
+		size += rest[0]
+		rest = rest[1:]
+	}
+	if len(rest) > 1 {
+		return Budget{}, fmt.Errorf("budget for %s: invalid budget %q", output, budget)
+	}
+
+	number := strings.TrimRight(size, "abcdefghijklmnopqrstuvwxyz")
+	unit := size[len(number):]
+	if unit == "" {
+		unit = "b"
+	}
+	multiplier, ok := budgetUnits[unit]
+	value, err := strconv.ParseFloat(number, 64)
+	if !ok || err != nil || value < 0 {
+		return Budget{}, fmt.Errorf("budget for %s: invalid size %q, expected e.g. 500b, 12kb or 1.5mb", output, size)
+	}
+
+	compression := ""
+	if len(rest) == 1 {
+		compression = rest[0]
+	}
+	c, ok := budgetCompressions[compression]
+	if !ok {
+		return Budget{}, fmt.Errorf("budget for %s: invalid compression %q, expected gz or br", output, compression)
+	}
+
+	return Budget{
+		Output:      filepath.ToSlash(filepath.Clean(output)),
+		Limit:       int(value * float64(multiplier)),
+		Compression: c,
+	}, nil
+}
+
+// ParseBudgets parses the budgets of the configuration.
+func ParseBudgets(budgets map[string]string) ([]Budget, error) {
+	outputs := make([]string, 0, len(budgets))
+	for output := range budgets {
+		outputs = append(outputs, output)
+	}
+	sort.Strings(outputs)
+
+	parsed := make([]Budget, 0, len(budgets))
+	for _, output := range outputs {
+		budget, err := ParseBudget(output, budgets[output])
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, budget)
+	}
+	return parsed, nil
+}
+
+// Matches reports whether the budget applies to an output file, given relative to the package.
+func (b Budget) Matches(outputPath string) bool {
+	return MatchGlob(b.Output, filepath.ToSlash(filepath.Clean(outputPath)))
+}
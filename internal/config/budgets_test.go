@@ -0,0 +1,70 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBudget(t *testing.T) {
+	tests := []struct {
+		output  string
+		budget  string
+		want    Budget
+		wantErr string
+	}{
+		{output: "dist/index.js", budget: "500", want: Budget{Output: "dist/index.js", Limit: 500}},
+		{output: "./dist/index.js", budget: "12kb", want: Budget{Output: "dist/index.js", Limit: 12 * 1024}},
+		{output: "dist/index.js", budget: "12 KB gz", want: Budget{Output: "dist/index.js", Limit: 12 * 1024, Compression: CompressionGzip}},
+		{output: "dist/index.js", budget: "1.5mb brotli", want: Budget{Output: "dist/index.js", Limit: 1536 * 1024, Compression: CompressionBrotli}},
+		{output: "dist/*.js", budget: "100b raw", want: Budget{Output: "dist/*.js", Limit: 100}},
+		{output: "dist/index.js", budget: " ", wantErr: "budget for dist/index.js: empty budget"},
+		{output: "dist/index.js", budget: "12kb gz br", wantErr: `invalid budget "12kb gz br"`},
+		{output: "dist/index.js", budget: "12gb", wantErr: `invalid size "12gb"`},
+		{output: "dist/index.js", budget: "-1kb", wantErr: `invalid size "-1kb"`},
+		{output: "dist/index.js", budget: "kb", wantErr: `invalid size "kb"`},
+		{output: "dist/index.js", budget: "12kb zip", wantErr: `invalid compression "zip"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.budget, func(t *testing.T) {
+			got, err := ParseBudget(tt.output, tt.budget)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBudget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetMatches(t *testing.T) {
+	tests := []struct {
+		output     string
+		outputPath string
+		want       bool
+	}{
+		{output: "dist/index.js", outputPath: "./dist/index.js", want: true},
+		{output: "dist/*.js", outputPath: "dist/index.js", want: true},
+		{output: "dist/*.js", outputPath: "dist/index.cjs", want: false},
+		{output: "dist/**/*.js", outputPath: "dist/a/b.js", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.output+" "+tt.outputPath, func(t *testing.T) {
+			budget, err := ParseBudget(tt.output, "1kb")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := budget.Matches(tt.outputPath); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.outputPath, got, tt.want)
+			}
+		})
+	}
+}
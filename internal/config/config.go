@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 )
 
@@ -14,6 +17,13 @@ type BinField struct {
 	Multi  map[string]string
 }
 
+// BrowserField is the package.json browser field: either a replacement for main or a map of
+// files and modules to browser replacements, where false replaces a module with an empty one.
+type BrowserField struct {
+	Single string
+	Multi  map[string]interface{}
+}
+
 const (
 	PackageTypeModule   PackageType = "module"
 	PackageTypeCommonJS PackageType = "commonjs"
@@ -26,8 +36,65 @@ type ExportEntry struct {
 	Platform     string
 	IsExecutable bool
 	From         string
+	// Subpath is the exports key the entry belongs to, e.g. "." or "./utils/*"
+	Subpath string
+	// Conditions lists the export conditions leading to the entry, other than the generic
+	// import, require, types and default conditions
+	Conditions []string
+	// Env holds compile-time environment variables implied by the conditions, e.g. NODE_ENV
+	Env map[string]string
+	// Options are the options of the configuration overrides matching the entry
+	Options EntryOptions
+}
+
+// conditionPlatforms maps export conditions to the platform their targets are built for
+var conditionPlatforms = map[string]string{
+	"node":         "node",
+	"node-addons":  "node",
+	"browser":      "browser",
+	"worker":       "neutral",
+	"deno":         "neutral",
+	"react-native": "neutral",
+	"edge-light":   "neutral",
+	"workerd":      "neutral",
+}
+
+// otherConditions are conditions of Node, bundlers and runtimes that imply neither a platform nor
+// an environment
+var otherConditions = map[string]bool{
+	"module":       true,
+	"module-sync":  true,
+	"bun":          true,
+	"electron":     true,
+	"react-server": true,
+	"style":        true,
+	"svelte":       true,
+}
+
+// IsKnownCondition reports whether squish or the tools consuming packages know an export
+// condition. Other conditions are custom ones the package has to declare.
+func IsKnownCondition(condition string) bool {
+	_, platform := conditionPlatforms[condition]
+	_, env := conditionNodeEnvs[condition]
+	return platform || env || genericConditions[condition] || otherConditions[condition]
+}
+
+// conditionNodeEnvs maps export conditions to the NODE_ENV their targets are built with
+var conditionNodeEnvs = map[string]string{
+	"development": "development",
+	"production":  "production",
+}
+
+// genericConditions only select a format and apply to every platform
+var genericConditions = map[string]bool{
+	"import":  true,
+	"require": true,
+	"types":   true,
+	"default": true,
 }
 
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
 type PackageJSON struct {
 	Name             string                 `json:"name"`
 	Version          string                 `json:"version"`
@@ -36,10 +103,14 @@ type PackageJSON struct {
 	Module           string                 `json:"module"`
 	Types            string                 `json:"types"`
 	Bin              BinField               `json:"bin"`
+	Browser          BrowserField           `json:"browser"`
 	Exports          map[string]interface{} `json:"exports"`
 	Dependencies     map[string]string      `json:"dependencies"`
 	PeerDependencies map[string]string      `json:"peerDependencies"`
 	DevDependencies  map[string]string      `json:"devDependencies"`
+	Engines          map[string]string      `json:"engines"`
+	Browserslist     Browserslist           `json:"browserslist"`
+	Squish           json.RawMessage        `json:"squish"`
 }
 
 func ReadPackageJSON(dir string) (*PackageJSON, error) {
@@ -105,84 +176,70 @@ func (p *PackageJSON) GetExportEntries() ([]ExportEntry, error) {
 				OutputPath:   binPath,
 				Type:         getFileType(binPath, p.Type),
 				IsExecutable: true,
-				From:         fmt.Sprintf("bin.%s", binNames),
+				From:         JSONPath("bin", binNames),
+			})
+		}
+	}
+
+	// Handle browser entries, both the main replacement and replacements of output files
+	if p.Browser.Single != "" {
+		entries = append(entries, ExportEntry{
+			OutputPath: p.Browser.Single,
+			Type:       getFileType(p.Browser.Single, p.Type),
+			Platform:   "browser",
+			From:       "browser",
+		})
+	} else if len(p.Browser.Multi) > 0 {
+		for _, source := range sortedKeys(p.Browser.Multi) {
+			replacement, ok := p.Browser.Multi[source].(string)
+			if !ok || !strings.HasPrefix(source, "./") || !strings.HasPrefix(replacement, "./") {
+				continue
+			}
+			entries = append(entries, ExportEntry{
+				OutputPath: replacement,
+				Type:       getFileType(replacement, p.Type),
+				Platform:   "browser",
+				From:       JSONPath("browser", source),
 			})
 		}
 	}
 
 	// Handle exports
-	if err := p.parseExports(p.Exports, &entries, "exports"); err != nil {
+	if err := p.parseExports(p.Exports, &entries, "exports", ".", nil); err != nil {
 		return nil, err
 	}
 
 	return entries, nil
 }
 
-func (p *PackageJSON) parseExports(exports interface{}, entries *[]ExportEntry, from string) error {
+func (p *PackageJSON) parseExports(exports interface{}, entries *[]ExportEntry, from, subpath string, conditions []string) error {
 	switch e := exports.(type) {
+	case nil:
+		// A null target excludes the subpath, see GetExcludedSubpaths
 	case string:
 		if strings.HasPrefix(e, "./") {
-			*entries = append(*entries, ExportEntry{
-				OutputPath: e,
-				Type:       getFileType(e, p.Type),
-				From:       from,
-			})
+			*entries = append(*entries, p.newConditionalEntry(e, from, subpath, conditions))
 		}
 	case map[string]interface{}:
-		for key, value := range e {
-			newFrom := fmt.Sprintf("%s.%s", from, key)
-			switch key {
-			case "require":
-				if path, ok := value.(string); ok {
-					*entries = append(*entries, ExportEntry{
-						OutputPath: path,
-						Type:       PackageTypeCommonJS,
-						From:       newFrom,
-					})
-				}
-			case "import":
-				if path, ok := value.(string); ok {
-					*entries = append(*entries, ExportEntry{
-						OutputPath: path,
-						Type:       PackageTypeModule,
-						From:       newFrom,
-					})
-				}
-			case "types":
-				if path, ok := value.(string); ok {
-					*entries = append(*entries, ExportEntry{
-						OutputPath: path,
-						Type:       PackageTypeTypes,
-						From:       newFrom,
-					})
-				}
-			case "node":
-				if path, ok := value.(string); ok {
-					*entries = append(*entries, ExportEntry{
-						OutputPath: path,
-						Type:       getFileType(path, p.Type),
-						Platform:   "node",
-						From:       newFrom,
-					})
-				}
-			case "default":
-				if path, ok := value.(string); ok {
-					*entries = append(*entries, ExportEntry{
-						OutputPath: path,
-						Type:       getFileType(path, p.Type),
-						From:       newFrom,
-					})
-				}
-			default:
-				if err := p.parseExports(value, entries, newFrom); err != nil {
+		for _, key := range sortedKeys(e) {
+			newFrom := JSONPath(from, key)
+			if strings.HasPrefix(key, ".") {
+				if err := p.parseExports(e[key], entries, newFrom, key, nil); err != nil {
 					return err
 				}
+				continue
+			}
+
+			// Every other key is a condition, which applies to all targets nested below it
+			nested := append(append([]string{}, conditions...), key)
+			if err := p.parseExports(e[key], entries, newFrom, subpath, nested); err != nil {
+				return err
 			}
 		}
 	case []interface{}:
 		for i, value := range e {
 			newFrom := fmt.Sprintf("%s[%d]", from, i)
-			if err := p.parseExports(value, entries, newFrom); err != nil {
+			if err := p.parseExports(value, entries, newFrom, subpath, conditions); err != nil {
 				return err
 			}
 		}
@@ -192,6 +249,140 @@ func (p *PackageJSON) parseExports(exports interface{}, entries *[]ExportEntry,
 	return nil
 }
 
+// newConditionalEntry creates the entry for an export target reached through conditions, e.g.
+// exports["."].browser.import, deriving its format, platform and environment from them.
+func (p *PackageJSON) newConditionalEntry(path, from, subpath string, conditions []string) ExportEntry {
+	entry := ExportEntry{
+		OutputPath: path,
+		Type:       getFileType(path, p.Type),
+		From:       from,
+		Subpath:    subpath,
+	}
+
+	for _, condition := range conditions {
+		switch condition {
+		case "types":
+			entry.Type = PackageTypeTypes
+		case "require":
+			if entry.Type != PackageTypeTypes {
+				entry.Type = PackageTypeCommonJS
+			}
+		case "import", "module", "module-sync":
+			if entry.Type != PackageTypeTypes {
+				entry.Type = PackageTypeModule
+			}
+		}
+
+		if platform, ok := conditionPlatforms[condition]; ok {
+			entry.Platform = platform
+		}
+
+		if nodeEnv, ok := conditionNodeEnvs[condition]; ok {
+			entry.Env = map[string]string{"NODE_ENV": nodeEnv}
+		}
+
+		if !genericConditions[condition] {
+			entry.Conditions = append(entry.Conditions, condition)
+		}
+	}
+
+	return entry
+}
+
+// JSONPath appends a key to a JSON path, e.g. exports["./utils"].import
+func JSONPath(from, key string) string {
+	if identifierPattern.MatchString(key) {
+		return from + "." + key
+	}
+	return fmt.Sprintf("%s[%q]", from, key)
+}
+
+// MergeExportEntries merges entries that resolve to the same output file, so each file is built once.
+// Entries that disagree on the format, platform or environment of a file are reported as an error.
+func MergeExportEntries(entries []ExportEntry) ([]ExportEntry, error) {
+	merged := make([]ExportEntry, 0, len(entries))
+	indexByPath := make(map[string]int)
+
+	for _, entry := range entries {
+		outputPath := filepath.Clean(entry.OutputPath)
+		i, ok := indexByPath[outputPath]
+		if !ok {
+			indexByPath[outputPath] = len(merged)
+			merged = append(merged, entry)
+			continue
+		}
+
+		existing := &merged[i]
+		if existing.Type != entry.Type {
+			return nil, fmt.Errorf("conflicting formats for %s: %s declares %s, %s declares %s",
+				entry.OutputPath, existing.From, existing.Type, entry.From, entry.Type)
+		}
+		if existing.Platform != "" && entry.Platform != "" && existing.Platform != entry.Platform {
+			return nil, fmt.Errorf("conflicting platforms for %s: %s declares %s, %s declares %s",
+				entry.OutputPath, existing.From, existing.Platform, entry.From, entry.Platform)
+		}
+
+		if !sameEnv(existing.Env, entry.Env) {
+			return nil, fmt.Errorf("conflicting environments for %s: %s is built with %s, %s with %s, point them at separate files",
+				entry.OutputPath, existing.From, describeEnv(existing.Env), entry.From, describeEnv(entry.Env))
+		}
+
+		if existing.Platform == "" {
+			existing.Platform = entry.Platform
+		}
+		for _, condition := range entry.Conditions {
+			if !slices.Contains(existing.Conditions, condition) {
+				existing.Conditions = append(existing.Conditions, condition)
+			}
+		}
+		if existing.Subpath == "" {
+			existing.Subpath = entry.Subpath
+		}
+		existing.IsExecutable = existing.IsExecutable || entry.IsExecutable
+	}
+
+	return merged, nil
+}
+
+// sameEnv reports whether two entries imply the same environment variables. An entry without any
+// conflicts with one that has some, as merging them would give every consumer the other's build.
+func sameEnv(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if b[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// describeEnv lists environment variables as they are set, e.g. NODE_ENV=production.
+func describeEnv(env map[string]string) string {
+	if len(env) == 0 {
+		return "no environment variables"
+	}
+	vars := make([]string, 0, len(env))
+	for key, value := range env {
+		vars = append(vars, key+"="+value)
+	}
+	sort.Strings(vars)
+	return strings.Join(vars, " ")
+}
+
+// GetExcludedSubpaths returns the exports subpaths and subpath patterns mapped to null,
+// e.g. "./internal/*" for `"./internal/*": null`.
+func (p *PackageJSON) GetExcludedSubpaths() []string {
+	excluded := []string{}
+	for key, value := range p.Exports {
+		if strings.HasPrefix(key, ".") && value == nil {
+			excluded = append(excluded, key)
+		}
+	}
+	return excluded
+}
+
 func (b *BinField) UnmarshalJSON(data []byte) error {
 	var s string
 	if err := json.Unmarshal(data, &s); err == nil {
@@ -208,13 +399,49 @@ func (b *BinField) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("bin field must be either a string or a map[string]string")
 }
 
+func (b *BrowserField) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		b.Single = s
+		return nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err == nil {
+		b.Multi = m
+		return nil
+	}
+
+	return fmt.Errorf("browser field must be either a string or an object")
+}
+
+// Modules returns the bare module names the browser field replaces, e.g. "fs" for `"fs": false`.
+func (b *BrowserField) Modules() []string {
+	modules := []string{}
+	for _, key := range sortedKeys(b.Multi) {
+		if !strings.HasPrefix(key, ".") && !strings.HasPrefix(key, "/") {
+			modules = append(modules, key)
+		}
+	}
+	return modules
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func getFileType(filePath string, defaultType PackageType) PackageType {
 	switch {
 	case strings.HasSuffix(filePath, ".mjs"):
 		return PackageTypeModule
 	case strings.HasSuffix(filePath, ".cjs"):
 		return PackageTypeCommonJS
-	case strings.HasSuffix(filePath, ".d.ts"):
+	case strings.HasSuffix(filePath, ".d.ts"), strings.HasSuffix(filePath, ".d.mts"), strings.HasSuffix(filePath, ".d.cts"):
 		return PackageTypeTypes
 	default:
 		return defaultType
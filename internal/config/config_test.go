@@ -0,0 +1,110 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeExportEntries(t *testing.T) {
+	production := map[string]string{"NODE_ENV": "production"}
+	development := map[string]string{"NODE_ENV": "development"}
+
+	tests := []struct {
+		name    string
+		entries []ExportEntry
+		want    []ExportEntry
+		wantErr string
+	}{
+		{
+			name: "distinct outputs",
+			entries: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "main"},
+				{OutputPath: "./dist/cli.js", Type: PackageTypeModule, From: "bin"},
+			},
+			want: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "main"},
+				{OutputPath: "./dist/cli.js", Type: PackageTypeModule, From: "bin"},
+			},
+		},
+		{
+			name: "same output spelled differently",
+			entries: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "module"},
+				{OutputPath: "dist/index.js", Type: PackageTypeModule, From: "exports.browser", Subpath: ".", Platform: "browser", Conditions: []string{"browser"}},
+				{OutputPath: "./dist/./index.js", Type: PackageTypeModule, From: "bin", IsExecutable: true, Conditions: []string{"browser", "worker"}},
+			},
+			want: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "module", Subpath: ".", Platform: "browser", Conditions: []string{"browser", "worker"}, IsExecutable: true},
+			},
+		},
+		{
+			name: "same environment",
+			entries: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.production", Env: production},
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.browser.production", Platform: "browser", Env: map[string]string{"NODE_ENV": "production"}},
+			},
+			want: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.production", Platform: "browser", Env: production},
+			},
+		},
+		{
+			name: "conflicting formats",
+			entries: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.import"},
+				{OutputPath: "./dist/index.js", Type: PackageTypeCommonJS, From: "exports.require"},
+			},
+			wantErr: "conflicting formats for ./dist/index.js: exports.import declares module, exports.require declares commonjs",
+		},
+		{
+			name: "conflicting platforms",
+			entries: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.node", Platform: "node"},
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.browser", Platform: "browser"},
+			},
+			wantErr: "conflicting platforms for ./dist/index.js",
+		},
+		{
+			name: "conflicting environments",
+			entries: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.production", Env: production},
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.development", Env: development},
+			},
+			wantErr: "conflicting environments for ./dist/index.js: exports.production is built with NODE_ENV=production, exports.development with NODE_ENV=development",
+		},
+		{
+			name: "environment and none",
+			entries: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "main"},
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.development", Env: development},
+			},
+			wantErr: "conflicting environments for ./dist/index.js: main is built with no environment variables, exports.development with NODE_ENV=development",
+		},
+		{
+			name: "none and environment",
+			entries: []ExportEntry{
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.production", Env: production},
+				{OutputPath: "./dist/index.js", Type: PackageTypeModule, From: "exports.import"},
+			},
+			wantErr: "conflicting environments for ./dist/index.js",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeExportEntries(tt.entries)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeExportEntries() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFileName is the configuration file squish reads from the package directory
+const ConfigFileName = "squish.config.json"
+
+// EntryOptions are the options that can be set for individual entries through overrides. Unset
+// options are nil so configuration sources can be layered on top of each other.
+type EntryOptions struct {
+	Minify           *bool             `json:"minify,omitempty"`
+	Target           []string          `json:"target,omitempty"`
+	Env              map[string]string `json:"env,omitempty"`
+	ExportConditions []string          `json:"exportConditions,omitempty"`
+	Sourcemap        *string           `json:"sourcemap,omitempty"`
+	Platform         *string           `json:"platform,omitempty"`
+	BrowserBuiltins  *string           `json:"browserBuiltins,omitempty"`
+	CjsInterop       *bool             `json:"cjsInterop,omitempty"`
+}
+
+// Options are the options that can be set in the configuration file and on the command line.
+type Options struct {
+	Src         *string `json:"src,omitempty"`
+	Dist        *string `json:"dist,omitempty"`
+	Tsconfig    *string `json:"tsconfig,omitempty"`
+	CleanDist   *bool   `json:"cleanDist,omitempty"`
+	Bundle      *bool   `json:"bundle,omitempty"`
+	Concurrency *int    `json:"concurrency,omitempty"`
+	Metafile    *bool   `json:"metafile,omitempty"`
+	Unbundled   *bool   `json:"unbundled,omitempty"`
+	// CustomConditions declares the custom export conditions used in the package.json exports
+	CustomConditions []string `json:"customConditions,omitempty"`
+	// Budgets map output files or globs to the size they may not exceed, e.g. "12kb gz"
+	Budgets map[string]string `json:"budgets,omitempty"`
+	EntryOptions
+}
+
+// Override applies options to the entries of an exports subpath, e.g. "./cli" or "./utils/*", or
+// to the entries whose output file matches a glob, e.g. "dist/*.cjs" where ** matches any number
+// of directories. When both are set an entry has to match both.
+type Override struct {
+	Subpath string `json:"subpath,omitempty"`
+	Output  string `json:"output,omitempty"`
+	EntryOptions
+}
+
+// SquishConfig is the content of squish.config.json or the "squish" key of package.json.
+type SquishConfig struct {
+	Schema string `json:"$schema,omitempty"`
+	Options
+	Overrides []Override `json:"overrides,omitempty"`
+	// Source is the file the configuration was read from, empty when there is none
+	Source string `json:"-"`
+}
+
+// LoadSquishConfig reads the configuration from squish.config.json or the "squish" key of
+// package.json and validates it against the configuration schema. A package without either
+// gets an empty configuration.
+func LoadSquishConfig(dir string, pkg *PackageJSON) (*SquishConfig, error) {
+	configPath := filepath.Join(dir, ConfigFileName)
+	data, err := os.ReadFile(configPath)
+	switch {
+	case err == nil:
+		if len(pkg.Squish) > 0 {
+			return nil, fmt.Errorf("both %s and the \"squish\" key of package.json configure squish, use only one", ConfigFileName)
+		}
+		return parseSquishConfig(data, ConfigFileName)
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read %s: %w", ConfigFileName, err)
+	case len(pkg.Squish) > 0:
+		return parseSquishConfig(pkg.Squish, "package.json#squish")
+	default:
+		return &SquishConfig{}, nil
+	}
+}
+
+func parseSquishConfig(data []byte, source string) (*SquishConfig, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if err := validateSchema(raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	var c SquishConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	c.Source = source
+
+	return &c, nil
+}
+
+// Resolve layers options set on the command line over the configuration. Command line options
+// also take precedence over overrides, so they are removed from the returned overrides.
+func (c *SquishConfig) Resolve(flags Options) (Options, []Override) {
+	options := c.Options.Merge(flags)
+
+	overrides := make([]Override, 0, len(c.Overrides))
+	for _, override := range c.Overrides {
+		override.EntryOptions = override.EntryOptions.without(flags.EntryOptions)
+		overrides = append(overrides, override)
+	}
+
+	return options, overrides
+}
+
+// Merge returns the options with every option set in other replacing its own. Environment
+// variables are merged by name.
+func (o Options) Merge(other Options) Options {
+	if other.Src != nil {
+		o.Src = other.Src
+	}
+	if other.Dist != nil {
+		o.Dist = other.Dist
+	}
+	if other.Tsconfig != nil {
+		o.Tsconfig = other.Tsconfig
+	}
+	if other.CleanDist != nil {
+		o.CleanDist = other.CleanDist
+	}
+	if other.Bundle != nil {
+		o.Bundle = other.Bundle
+	}
+	if other.Concurrency != nil {
+		o.Concurrency = other.Concurrency
+	}
+	if other.Metafile != nil {
+		o.Metafile = other.Metafile
+	}
+	if other.Unbundled != nil {
+		o.Unbundled = other.Unbundled
+	}
+	if other.CustomConditions != nil {
+		o.CustomConditions = other.CustomConditions
+	}
+	if other.Budgets != nil {
+		budgets := make(map[string]string, len(o.Budgets)+len(other.Budgets))
+		for output, budget := range o.Budgets {
+			budgets[output] = budget
+		}
+		for output, budget := range other.Budgets {
+			budgets[output] = budget
+		}
+		o.Budgets = budgets
+	}
+	o.EntryOptions = o.EntryOptions.Merge(other.EntryOptions)
+	return o
+}
+
+// Merge returns the options with every option set in other replacing its own. Environment
+// variables are merged by name.
+func (o EntryOptions) Merge(other EntryOptions) EntryOptions {
+	if other.Minify != nil {
+		o.Minify = other.Minify
+	}
+	if other.Target != nil {
+		o.Target = other.Target
+	}
+	if other.Env != nil {
+		env := make(map[string]string, len(o.Env)+len(other.Env))
+		for key, value := range o.Env {
+			env[key] = value
+		}
+		for key, value := range other.Env {
+			env[key] = value
+		}
+		o.Env = env
+	}
+	if other.ExportConditions != nil {
+		o.ExportConditions = other.ExportConditions
+	}
+	if other.Sourcemap != nil {
+		o.Sourcemap = other.Sourcemap
+	}
+	if other.Platform != nil {
+		o.Platform = other.Platform
+	}
+	if other.BrowserBuiltins != nil {
+		o.BrowserBuiltins = other.BrowserBuiltins
+	}
+	if other.CjsInterop != nil {
+		o.CjsInterop = other.CjsInterop
+	}
+	return o
+}
+
+// without returns the options with every option set in other unset.
+func (o EntryOptions) without(other EntryOptions) EntryOptions {
+	if other.Minify != nil {
+		o.Minify = nil
+	}
+	if other.Target != nil {
+		o.Target = nil
+	}
+	if other.Env != nil && o.Env != nil {
+		env := make(map[string]string, len(o.Env))
+		for key, value := range o.Env {
+			if _, ok := other.Env[key]; !ok {
+				env[key] = value
+			}
+		}
+		o.Env = env
+	}
+	if other.ExportConditions != nil {
+		o.ExportConditions = nil
+	}
+	if other.Sourcemap != nil {
+		o.Sourcemap = nil
+	}
+	if other.Platform != nil {
+		o.Platform = nil
+	}
+	if other.BrowserBuiltins != nil {
+		o.BrowserBuiltins = nil
+	}
+	if other.CjsInterop != nil {
+		o.CjsInterop = nil
+	}
+	return o
+}
+
+// Matches reports whether the override applies to an entry.
+func (o Override) Matches(entry ExportEntry) bool {
+	if o.Subpath == "" && o.Output == "" {
+		return false
+	}
+	if o.Subpath != "" && !matchSubpath(o.Subpath, entry.Subpath) {
+		return false
+	}
+	if o.Output != "" && !MatchGlob(filepath.ToSlash(filepath.Clean(o.Output)), filepath.ToSlash(filepath.Clean(entry.OutputPath))) {
+		return false
+	}
+	return true
+}
+
+// ApplyOverrides sets the options of every override matching an entry on it, later overrides
+// taking precedence over earlier ones.
+func ApplyOverrides(entries []ExportEntry, overrides []Override) []ExportEntry {
+	for i := range entries {
+		for _, override := range overrides {
+			if override.Matches(entries[i]) {
+				entries[i].Options = entries[i].Options.Merge(override.EntryOptions)
+			}
+		}
+	}
+	return entries
+}
+
+// matchSubpath matches an exports subpath against a subpath or subpath pattern, e.g. "./utils/*",
+// where like in Node "*" may span several directories.
+func matchSubpath(pattern, subpath string) bool {
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok {
+		return pattern == subpath
+	}
+	return len(subpath) >= len(prefix)+len(suffix) && strings.HasPrefix(subpath, prefix) && strings.HasSuffix(subpath, suffix)
+}
+
+// MatchGlob matches a slash separated path against a glob in which ** matches any number of
+// path segments and every other segment is matched with path.Match.
+func MatchGlob(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchGlobSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+	return matchGlobSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestMatchSubpath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		subpath string
+		want    bool
+	}{
+		{pattern: "./cli", subpath: "./cli", want: true},
+		{pattern: "./cli", subpath: "./client", want: false},
+		{pattern: "./utils/*", subpath: "./utils/string", want: true},
+		{pattern: "./utils/*", subpath: "./utils/string/trim", want: true},
+		{pattern: "./utils/*", subpath: "./utils", want: false},
+		{pattern: "./*.js", subpath: "./index.js", want: true},
+		{pattern: "./*.js", subpath: "./index.cjs", want: false},
+		{pattern: "./a*a", subpath: "./a", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.subpath, func(t *testing.T) {
+			if got := matchSubpath(tt.pattern, tt.subpath); got != tt.want {
+				t.Errorf("matchSubpath(%q, %q) = %v, want %v", tt.pattern, tt.subpath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "dist/index.js", name: "dist/index.js", want: true},
+		{pattern: "dist/*.cjs", name: "dist/index.cjs", want: true},
+		{pattern: "dist/*.cjs", name: "dist/index.js", want: false},
+		{pattern: "dist/*.cjs", name: "dist/cjs/index.cjs", want: false},
+		{pattern: "dist/**/*.cjs", name: "dist/index.cjs", want: true},
+		{pattern: "dist/**/*.cjs", name: "dist/a/b/index.cjs", want: true},
+		{pattern: "**", name: "dist/a/index.js", want: true},
+		{pattern: "packages/*", name: "packages/core", want: true},
+		{pattern: "packages/*", name: "packages/core/src", want: false},
+		{pattern: "packages/**", name: "apps/web", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverrideMatches(t *testing.T) {
+	entry := ExportEntry{Subpath: "./utils/string", OutputPath: "./dist/utils/string.cjs"}
+
+	tests := []struct {
+		name     string
+		override Override
+		want     bool
+	}{
+		{name: "no subpath or output", override: Override{}, want: false},
+		{name: "subpath", override: Override{Subpath: "./utils/string"}, want: true},
+		{name: "subpath pattern", override: Override{Subpath: "./utils/*"}, want: true},
+		{name: "other subpath", override: Override{Subpath: "./cli"}, want: false},
+		{name: "output", override: Override{Output: "dist/utils/string.cjs"}, want: true},
+		{name: "output glob", override: Override{Output: "./dist/**/*.cjs"}, want: true},
+		{name: "other output", override: Override{Output: "dist/*.cjs"}, want: false},
+		{name: "subpath and output", override: Override{Subpath: "./utils/*", Output: "dist/**/*.cjs"}, want: true},
+		{name: "subpath and other output", override: Override{Subpath: "./utils/*", Output: "dist/**/*.mjs"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.override.Matches(entry); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Schema is the JSON schema of the configuration file, for editors and `squish config schema`
+//
+//go:embed squish.schema.json
+var Schema []byte
+
+// jsonSchema is the subset of JSON schema used by the configuration schema
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Enum                 []interface{}          `json:"enum"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Required             []string               `json:"required"`
+	AnyOf                []*jsonSchema          `json:"anyOf"`
+	MinLength            *int                   `json:"minLength"`
+	Minimum              *float64               `json:"minimum"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+}
+
+// validateSchema validates a decoded configuration against the configuration schema, reporting
+// the first violation with the JSON path of the offending value.
+func validateSchema(value interface{}) error {
+	var root jsonSchema
+	if err := json.Unmarshal(Schema, &root); err != nil {
+		return fmt.Errorf("invalid configuration schema: %w", err)
+	}
+	return root.validate(&root, value, "")
+}
+
+func (s *jsonSchema) validate(root *jsonSchema, value interface{}, path string) error {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		definition, ok := root.Definitions[name]
+		if !ok {
+			return fmt.Errorf("invalid configuration schema: unknown reference %s", s.Ref)
+		}
+		return definition.validate(root, value, path)
+	}
+
+	if s.Type != "" && !matchesSchemaType(s.Type, value) {
+		return schemaError(path, "expected %s, got %s", s.Type, schemaTypeName(value))
+	}
+
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+		allowed := make([]string, 0, len(s.Enum))
+		for _, v := range s.Enum {
+			allowed = append(allowed, fmt.Sprintf("%q", v))
+		}
+		return schemaError(path, "expected one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+
+	switch v := value.(type) {
+	case string:
+		if s.MinLength != nil && len(v) < *s.MinLength {
+			return schemaError(path, "must not be empty")
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return schemaError(path, "must be at least %v", *s.Minimum)
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(root, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]interface{}:
+		if err := s.validateObject(root, v, path); err != nil {
+			return err
+		}
+	}
+
+	if len(s.AnyOf) > 0 {
+		messages := make([]string, 0, len(s.AnyOf))
+		for _, option := range s.AnyOf {
+			err := option.validate(root, value, path)
+			if err == nil {
+				return nil
+			}
+			if validationErr, ok := err.(*schemaValidationError); ok {
+				messages = append(messages, validationErr.message)
+			} else {
+				return err
+			}
+		}
+		return schemaError(path, "%s", strings.Join(messages, " or "))
+	}
+
+	return nil
+}
+
+func (s *jsonSchema) validateObject(root *jsonSchema, object map[string]interface{}, path string) error {
+	for _, key := range s.Required {
+		if _, ok := object[key]; !ok {
+			return schemaError(path, "missing %q", key)
+		}
+	}
+
+	var additional *jsonSchema
+	allowAdditional := true
+	if len(s.AdditionalProperties) > 0 {
+		if err := json.Unmarshal(s.AdditionalProperties, &allowAdditional); err != nil {
+			allowAdditional = true
+			additional = &jsonSchema{}
+			if err := json.Unmarshal(s.AdditionalProperties, additional); err != nil {
+				return fmt.Errorf("invalid configuration schema: %w", err)
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(object) {
+		propertyPath := JSONPath(path, key)
+		if property, ok := s.Properties[key]; ok {
+			if err := property.validate(root, object[key], propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !allowAdditional {
+			return schemaError(propertyPath, "unknown option")
+		}
+		if additional != nil {
+			if err := additional.validate(root, object[key], propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func matchesSchemaType(schemaType string, value interface{}) bool {
+	switch v := value.(type) {
+	case float64:
+		return schemaType == "number" || (schemaType == "integer" && v == math.Trunc(v))
+	default:
+		return schemaType == schemaTypeName(value)
+	}
+}
+
+func schemaTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+type schemaValidationError struct {
+	path    string
+	message string
+}
+
+func (e *schemaValidationError) Error() string {
+	if e.path == "" {
+		return e.message
+	}
+	return e.path + ": " + e.message
+}
+
+func schemaError(path, format string, args ...interface{}) error {
+	return &schemaValidationError{path: strings.TrimPrefix(path, "."), message: fmt.Sprintf(format, args...)}
+}
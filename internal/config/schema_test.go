@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "empty", config: `{}`},
+		{
+			name:   "valid",
+			config: `{"dist": "lib", "concurrency": 2, "target": ["es2020"], "budgets": {"dist/*.js": "10kb gz"}, "overrides": [{"subpath": "./cli", "platform": "node"}]}`,
+		},
+		{name: "not an object", config: `[]`, wantErr: "expected object, got array"},
+		{name: "unknown option", config: `{"outDir": "lib"}`, wantErr: "outDir: unknown option"},
+		{name: "wrong type", config: `{"minify": "yes"}`, wantErr: "minify: expected boolean, got string"},
+		{name: "empty string", config: `{"dist": ""}`, wantErr: "dist: must not be empty"},
+		{name: "not an integer", config: `{"concurrency": 1.5}`, wantErr: "concurrency: expected integer, got number"},
+		{name: "below minimum", config: `{"concurrency": -1}`, wantErr: "concurrency: must be at least 0"},
+		{name: "enum", config: `{"sourcemap": "external"}`, wantErr: `sourcemap: expected one of "", "linked", "inline", got "external"`},
+		{name: "array item", config: `{"target": ["es2020", 5]}`, wantErr: "target[1]: expected string, got number"},
+		{name: "additional property", config: `{"budgets": {"dist/index.js": 10}}`, wantErr: `budgets["dist/index.js"]: expected string, got number`},
+		{name: "override without subpath or output", config: `{"overrides": [{"minify": true}]}`, wantErr: `overrides[0]: missing "subpath" or missing "output"`},
+		{name: "override option", config: `{"overrides": [{"subpath": "./cli", "platform": "deno"}]}`, wantErr: `overrides[0].platform: expected one of "node", "browser", "neutral", got "deno"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.config), &value); err != nil {
+				t.Fatal(err)
+			}
+			err := validateSchema(value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
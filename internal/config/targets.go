@@ -0,0 +1,168 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Browserslist is the package.json browserslist field, either a single query or a list of queries
+type Browserslist []string
+
+var nodeVersionPattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+|x|\*))?(?:\.(\d+|x|\*))?$`)
+
+var browserQueryPattern = regexp.MustCompile(`^([a-z_]+)\s*(>=|>)?\s*(\d+(?:\.\d+)?)$`)
+
+// browserslistEngines maps browserslist browser names to esbuild target engines
+var browserslistEngines = map[string]string{
+	"chrome":        "chrome",
+	"and_chr":       "chrome",
+	"edge":          "edge",
+	"firefox":       "firefox",
+	"and_ff":        "firefox",
+	"ff":            "firefox",
+	"safari":        "safari",
+	"ios_saf":       "ios",
+	"ios":           "ios",
+	"opera":         "opera",
+	"node":          "node",
+	"ie":            "ie",
+	"explorer":      "ie",
+	"chromeandroid": "chrome",
+}
+
+func (b *Browserslist) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = []string{s}
+		return nil
+	}
+
+	var l []string
+	if err := json.Unmarshal(data, &l); err == nil {
+		*b = l
+		return nil
+	}
+
+	// Environment specific configurations such as {"production": [...]} are not supported
+	*b = nil
+	return nil
+}
+
+// GetNodeTarget returns the oldest node version engines.node allows as a target, e.g. "node18.12"
+// for ">=18.12.0". It returns an empty string when engines.node is missing or cannot be read.
+func (p *PackageJSON) GetNodeTarget() string {
+	var oldest []int
+	for _, set := range strings.Split(p.Engines["node"], "||") {
+		version := lowerBound(set)
+		if version == nil {
+			continue
+		}
+		if oldest == nil || compareVersions(version, oldest) < 0 {
+			oldest = version
+		}
+	}
+
+	if oldest == nil {
+		return ""
+	}
+	if oldest[1] == 0 && oldest[2] == 0 {
+		return fmt.Sprintf("node%d", oldest[0])
+	}
+	return fmt.Sprintf("node%d.%d.%d", oldest[0], oldest[1], oldest[2])
+}
+
+// GetBrowserTargets converts the browserslist queries naming a browser and minimum version, e.g.
+// "chrome >= 90" or "safari 15", into targets. Other queries are returned as unsupported.
+func (p *PackageJSON) GetBrowserTargets() ([]string, []string) {
+	targets := []string{}
+	unsupported := []string{}
+	seen := map[string]string{}
+
+	for _, query := range p.Browserslist {
+		for _, part := range strings.Split(query, ",") {
+			part = strings.ToLower(strings.TrimSpace(part))
+			if part == "" {
+				continue
+			}
+
+			match := browserQueryPattern.FindStringSubmatch(part)
+			engine, ok := "", false
+			if match != nil {
+				engine, ok = browserslistEngines[match[1]]
+			}
+			if !ok {
+				unsupported = append(unsupported, part)
+				continue
+			}
+
+			// Keep the oldest version per engine, as every listed browser must be supported
+			version := match[3]
+			if previous, ok := seen[engine]; ok && compareVersions(parseVersion(previous), parseVersion(version)) <= 0 {
+				continue
+			}
+			seen[engine] = version
+		}
+	}
+
+	for engine, version := range seen {
+		targets = append(targets, engine+version)
+	}
+	sort.Strings(targets)
+
+	return targets, unsupported
+}
+
+// lowerBound returns the lowest version a node semver range like ">=18.12.0 <21", "^20" or "18.x"
+// allows, or nil for ranges without one.
+func lowerBound(set string) []int {
+	fields := strings.Fields(strings.TrimSpace(set))
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// Hyphen ranges like "18 - 20" start at their first version
+	if len(fields) == 3 && fields[1] == "-" {
+		return parseVersion(fields[0])
+	}
+
+	for _, field := range fields {
+		if strings.HasPrefix(field, "<") {
+			continue
+		}
+		version := strings.TrimLeft(field, ">=^~")
+		if strings.HasPrefix(field, ">") && !strings.HasPrefix(field, ">=") {
+			// An exclusive bound is approximated by its version, which is close enough for a target
+			version = strings.TrimPrefix(field, ">")
+		}
+		if parsed := parseVersion(version); parsed != nil {
+			return parsed
+		}
+	}
+
+	return nil
+}
+
+func parseVersion(version string) []int {
+	match := nodeVersionPattern.FindStringSubmatch(strings.TrimSpace(version))
+	if match == nil {
+		return nil
+	}
+
+	parsed := make([]int, 3)
+	for i := 1; i <= 3; i++ {
+		fmt.Sscanf(match[i], "%d", &parsed[i-1])
+	}
+	return parsed
+}
+
+func compareVersions(a, b []int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGetNodeTarget(t *testing.T) {
+	tests := []struct {
+		engines string
+		want    string
+	}{
+		{engines: "", want: ""},
+		{engines: "*", want: ""},
+		{engines: ">=18", want: "node18"},
+		{engines: ">=18.12.0", want: "node18.12.0"},
+		{engines: "^20.1", want: "node20.1.0"},
+		{engines: "~16.14.2", want: "node16.14.2"},
+		{engines: "18.x", want: "node18"},
+		{engines: ">16", want: "node16"},
+		{engines: "<20", want: ""},
+		{engines: ">=16 <21", want: "node16"},
+		{engines: "18 - 20", want: "node18"},
+		{engines: ">=18 || ^14.17.0", want: "node14.17.0"},
+		{engines: "v20", want: "node20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.engines, func(t *testing.T) {
+			pkg := &PackageJSON{Engines: map[string]string{"node": tt.engines}}
+			if got := pkg.GetNodeTarget(); got != tt.want {
+				t.Errorf("GetNodeTarget() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetBrowserTargets(t *testing.T) {
+	tests := []struct {
+		name            string
+		browserslist    Browserslist
+		wantTargets     []string
+		wantUnsupported []string
+	}{
+		{
+			name:            "empty",
+			wantTargets:     []string{},
+			wantUnsupported: []string{},
+		},
+		{
+			name:            "browsers with versions",
+			browserslist:    Browserslist{"chrome >= 90, Firefox 88", "safari 15", "ios_saf 14.5"},
+			wantTargets:     []string{"chrome90", "firefox88", "ios14.5", "safari15"},
+			wantUnsupported: []string{},
+		},
+		{
+			name:            "oldest version per engine",
+			browserslist:    Browserslist{"chrome 100", "and_chr 95", "chrome >= 110"},
+			wantTargets:     []string{"chrome95"},
+			wantUnsupported: []string{},
+		},
+		{
+			name:            "unsupported queries",
+			browserslist:    Browserslist{"defaults", "last 2 versions, edge > 100", "not dead", "opera_mini 10"},
+			wantTargets:     []string{"edge100"},
+			wantUnsupported: []string{"defaults", "last 2 versions", "not dead", "opera_mini 10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg := &PackageJSON{Browserslist: tt.browserslist}
+			targets, unsupported := pkg.GetBrowserTargets()
+			if !reflect.DeepEqual(targets, tt.wantTargets) {
+				t.Errorf("targets = %q, want %q", targets, tt.wantTargets)
+			}
+			if !reflect.DeepEqual(unsupported, tt.wantUnsupported) {
+				t.Errorf("unsupported = %q, want %q", unsupported, tt.wantUnsupported)
+			}
+		})
+	}
+}
+
+func TestBrowserslistUnmarshal(t *testing.T) {
+	tests := []struct {
+		json string
+		want Browserslist
+	}{
+		{json: `"chrome 90"`, want: Browserslist{"chrome 90"}},
+		{json: `["chrome 90", "safari 15"]`, want: Browserslist{"chrome 90", "safari 15"}},
+		{json: `{"production": ["chrome 90"]}`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var got Browserslist
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Browserslist = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package utils
+
+import "fmt"
+
+// FormatBytes formats a size in bytes for humans, e.g. "512 B" or "12.3 KB"
+func FormatBytes(bytes int) string {
+	const unit = 1024
+	if bytes < unit && bytes > -unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	value := float64(bytes)
+	suffixes := []string{"KB", "MB", "GB"}
+	suffix := ""
+	for _, s := range suffixes {
+		value /= unit
+		suffix = s
+		if value < unit && value > -unit {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
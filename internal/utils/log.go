@@ -2,10 +2,17 @@ package utils
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"time"
 )
 
 func Log(messages ...interface{}) {
+	LogTo(os.Stdout, messages...)
+}
+
+// LogTo writes a log line to w, e.g. a buffer that is printed once a parallel build finishes
+func LogTo(w io.Writer, messages ...interface{}) {
 	currentTime := time.Now().Format("15:04:05")
-	fmt.Printf("[%s] %s\n", currentTime, fmt.Sprint(messages...))
+	fmt.Fprintf(w, "[%s] %s\n", currentTime, fmt.Sprint(messages...))
 }
@@ -17,3 +17,12 @@ func NormalizePath(filePath string, isDirectory bool) string {
 
 	return filepath.Clean(filePath)
 }
+
+// RelOrAbs returns target relative to base, or target itself when it cannot be made relative,
+// e.g. on another Windows drive
+func RelOrAbs(base, target string) string {
+	if rel, err := filepath.Rel(base, target); err == nil {
+		return rel
+	}
+	return target
+}
@@ -3,7 +3,9 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"path/filepath"
+	"sort"
 	"squish/internal/config"
 	"strings"
 )
@@ -24,7 +26,7 @@ type SourcePathResult struct {
 }
 
 func GetSourcePath(exportEntry config.ExportEntry, source, dist string) (*SourcePathResult, error) {
-	outputPath := exportEntry.OutputPath
+	outputPath := GetDistRelativePath(exportEntry.OutputPath, dist)
 
 	for distExtension, sourceExts := range extensionMap {
 		if strings.HasSuffix(outputPath, distExtension) {
@@ -42,10 +44,144 @@ func GetSourcePath(exportEntry config.ExportEntry, source, dist string) (*Source
 		}
 	}
 
-	outputPathJSON, _ := json.Marshal(outputPath)
+	outputPathJSON, _ := json.Marshal(exportEntry.OutputPath)
 	return nil, fmt.Errorf("could not find matching source file for export path %s", string(outputPathJSON))
 }
 
+// GetDistRelativePath returns the export output path relative to the dist directory.
+// Paths that do not live inside dist are treated as already being relative to it.
+func GetDistRelativePath(outputPath, dist string) string {
+	cleanOutput := filepath.Clean(outputPath)
+	cleanDist := filepath.Clean(dist)
+
+	if rel, err := filepath.Rel(cleanDist, cleanOutput); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+
+	return cleanOutput
+}
+
+// ExpandExportPatterns replaces entries whose output path contains a "*" subpath pattern with one
+// entry per matching file under the source directory. Subpaths matching an excluded pattern are dropped.
+func ExpandExportPatterns(entries []config.ExportEntry, excluded []string, source, dist string) ([]config.ExportEntry, error) {
+	expanded := make([]config.ExportEntry, 0, len(entries))
+
+	for _, entry := range entries {
+		if !strings.Contains(entry.OutputPath, "*") {
+			expanded = append(expanded, entry)
+			continue
+		}
+
+		matches, err := matchExportPattern(entry, source, dist)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range matches {
+			subpath := strings.ReplaceAll(entry.Subpath, "*", match)
+			if isSubpathExcluded(subpath, excluded) {
+				continue
+			}
+
+			patternEntry := entry
+			patternEntry.OutputPath = strings.ReplaceAll(entry.OutputPath, "*", match)
+			patternEntry.Subpath = subpath
+			expanded = append(expanded, patternEntry)
+		}
+	}
+
+	return expanded, nil
+}
+
+// matchExportPattern returns the values "*" takes for every source file that can produce the
+// entry's output path pattern. Like in Node, "*" may span several directories.
+func matchExportPattern(entry config.ExportEntry, source, dist string) ([]string, error) {
+	outputPattern := filepath.ToSlash(GetDistRelativePath(entry.OutputPath, dist))
+	prefix, rest, _ := strings.Cut(outputPattern, "*")
+
+	var distExtension string
+	var sourceExts []string
+	for ext, exts := range extensionMap {
+		if strings.HasSuffix(rest, ext) && len(ext) > len(distExtension) {
+			distExtension = ext
+			sourceExts = exts
+		}
+	}
+	if distExtension == "" {
+		outputPathJSON, _ := json.Marshal(entry.OutputPath)
+		return nil, fmt.Errorf("unsupported extension in export pattern %s", string(outputPathJSON))
+	}
+	suffix := strings.TrimSuffix(rest, distExtension)
+	if strings.Contains(suffix, "*") {
+		outputPathJSON, _ := json.Marshal(entry.OutputPath)
+		return nil, fmt.Errorf("export pattern %s may only contain a single \"*\"", string(outputPathJSON))
+	}
+
+	root := filepath.Join(source, filepath.FromSlash(prefix[:strings.LastIndex(prefix, "/")+1]))
+	if !FileExists(root) {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	matches := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Declaration files only ever provide declarations
+		if !strings.HasPrefix(distExtension, ".d.") && isDeclarationFile(rel) {
+			return nil
+		}
+
+		for _, ext := range sourceExts {
+			if !strings.HasSuffix(rel, ext) {
+				continue
+			}
+			withoutExt := strings.TrimSuffix(rel, ext)
+			if !strings.HasPrefix(withoutExt, prefix) || !strings.HasSuffix(withoutExt, suffix) || len(withoutExt) < len(prefix)+len(suffix) {
+				continue
+			}
+			match := withoutExt[len(prefix) : len(withoutExt)-len(suffix)]
+			if match != "" && !seen[match] {
+				seen[match] = true
+				matches = append(matches, match)
+			}
+			break
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func isDeclarationFile(path string) bool {
+	return strings.HasSuffix(path, ".d.ts") || strings.HasSuffix(path, ".d.mts") || strings.HasSuffix(path, ".d.cts")
+}
+
+func isSubpathExcluded(subpath string, excluded []string) bool {
+	for _, pattern := range excluded {
+		if pattern == subpath {
+			return true
+		}
+		prefix, suffix, found := strings.Cut(pattern, "*")
+		if found && strings.HasPrefix(subpath, prefix) && strings.HasSuffix(subpath, suffix) && len(subpath) >= len(prefix)+len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 type sourcePath struct {
 	path      string
 	extension string
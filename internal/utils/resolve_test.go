@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"reflect"
+	"slices"
+	"squish/internal/config"
+	"squish/internal/testutil"
+	"testing"
+)
+
+func patternSource(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"utils/a.ts":           "",
+		"utils/b.tsx":          "",
+		"utils/nested/c.ts":    "",
+		"utils/types.d.ts":     "",
+		"features/x/index.ts":  "",
+		"features/y/index.mts": "",
+		"features/z/readme.md": "",
+	})
+	return dir
+}
+
+func TestMatchExportPattern(t *testing.T) {
+	source := patternSource(t)
+	tests := []struct {
+		name    string
+		output  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "files spanning directories", output: "./dist/utils/*.js", want: []string{"a", "b", "nested/c"}},
+		{name: "directories", output: "./dist/features/*/index.mjs", want: []string{"x", "y"}},
+		{name: "declarations", output: "./dist/utils/*.d.ts", want: []string{"a", "nested/c", "types"}},
+		{name: "missing directory", output: "./dist/missing/*.js"},
+		{name: "unsupported extension", output: "./dist/*.css", wantErr: true},
+		{name: "several stars", output: "./dist/*/*.js", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchExportPattern(config.ExportEntry{OutputPath: tt.output}, source, "./dist")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matchExportPattern() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandExportPatterns(t *testing.T) {
+	source := patternSource(t)
+	entries := []config.ExportEntry{
+		{OutputPath: "./dist/index.js", Subpath: "."},
+		{OutputPath: "./dist/utils/*.js", Subpath: "./utils/*", Type: config.PackageTypeModule},
+	}
+
+	tests := []struct {
+		name     string
+		excluded []string
+		want     []config.ExportEntry
+	}{
+		{
+			name: "without exclusions",
+			want: []config.ExportEntry{
+				{OutputPath: "./dist/index.js", Subpath: "."},
+				{OutputPath: "./dist/utils/a.js", Subpath: "./utils/a", Type: config.PackageTypeModule},
+				{OutputPath: "./dist/utils/b.js", Subpath: "./utils/b", Type: config.PackageTypeModule},
+				{OutputPath: "./dist/utils/nested/c.js", Subpath: "./utils/nested/c", Type: config.PackageTypeModule},
+			},
+		},
+		{
+			name:     "excluded subpaths",
+			excluded: []string{"./utils/nested/*", "./utils/b"},
+			want: []config.ExportEntry{
+				{OutputPath: "./dist/index.js", Subpath: "."},
+				{OutputPath: "./dist/utils/a.js", Subpath: "./utils/a", Type: config.PackageTypeModule},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandExportPatterns(entries, tt.excluded, source, "./dist")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandExportPatterns() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
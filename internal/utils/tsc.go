@@ -1,34 +1,138 @@
 package utils
 
 import (
+	"bufio"
 	"fmt"
-	"os"
+	"io"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
-func RunTSC(srcDir, outDir, tsconfigPath string) error {
+// tscDiagnosticPattern matches diagnostics printed by tsc with --pretty false,
+// e.g. "src/index.ts(3,7): error TS2322: Type 'string' is not assignable to type 'number'."
+var tscDiagnosticPattern = regexp.MustCompile(`^(.+)\((\d+),(\d+)\): (error|warning) (TS\d+): (.*)$`)
+
+// tscGlobalDiagnosticPattern matches diagnostics that are not tied to a file, such as config errors.
+var tscGlobalDiagnosticPattern = regexp.MustCompile(`^(error|warning) (TS\d+): (.*)$`)
+
+type TSCDiagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity string
+	Code     string
+	Message  string
+}
+
+type TSCError struct {
+	Diagnostics []TSCDiagnostic
+	Output      string
+}
+
+func (e *TSCError) Error() string {
+	if len(e.Diagnostics) == 0 {
+		return fmt.Sprintf("tsc command failed: %s", strings.TrimSpace(e.Output))
+	}
+	return fmt.Sprintf("tsc reported %d error(s)", len(e.Diagnostics))
+}
+
+// RunTSC emits declaration files only into outDir. When a tsconfig is given the
+// project is compiled as configured, otherwise the inputs are compiled directly.
+func RunTSC(w io.Writer, inputs []string, outDir, tsconfigPath string) error {
 	args := []string{
-		"tsc",
 		"--declaration",
 		"--emitDeclarationOnly",
+		"--declarationMap", "false",
+		"--noEmit", "false",
+		"--pretty", "false",
 		"--outDir", outDir,
 	}
 
 	if tsconfigPath != "" {
 		args = append(args, "--project", tsconfigPath)
 	} else {
-		args = append(args, srcDir)
+		args = append(args, "--skipLibCheck", "--module", "esnext", "--moduleResolution", "bundler", "--target", "es2022")
+		args = append(args, inputs...)
 	}
 
-	cmd := exec.Command("npx", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := tscCommand(args)
 
-	Log("Running tsc command:", cmd.String())
-	err := cmd.Run()
+	LogTo(w, "Running tsc command: ", cmd.String())
+	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("tsc command failed: %w", err)
+		return &TSCError{
+			Diagnostics: parseTSCDiagnostics(string(output)),
+			Output:      string(output),
+		}
 	}
 
 	return nil
 }
+
+// RunTSCBuild builds a referenced project with tsc --build, which skips it when it is up to date.
+func RunTSCBuild(w io.Writer, tsconfigPath string) error {
+	cmd := tscCommand([]string{"--build", tsconfigPath, "--pretty", "false"})
+
+	LogTo(w, "Running tsc command: ", cmd.String())
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return &TSCError{
+			Diagnostics: parseTSCDiagnostics(string(output)),
+			Output:      string(output),
+		}
+	}
+
+	return nil
+}
+
+// tscCommand prefers the project's own TypeScript install and never lets npx download one.
+func tscCommand(args []string) *exec.Cmd {
+	localTSC := filepath.Join("node_modules", ".bin", "tsc")
+	if FileExists(localTSC) {
+		return exec.Command(localTSC, args...)
+	}
+	return exec.Command("npx", append([]string{"--no", "tsc"}, args...)...)
+}
+
+func parseTSCDiagnostics(output string) []TSCDiagnostic {
+	diagnostics := []TSCDiagnostic{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := tscDiagnosticPattern.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[2])
+			column, _ := strconv.Atoi(match[3])
+			diagnostics = append(diagnostics, TSCDiagnostic{
+				File:     match[1],
+				Line:     lineNumber,
+				Column:   column,
+				Severity: match[4],
+				Code:     match[5],
+				Message:  match[6],
+			})
+			continue
+		}
+
+		if match := tscGlobalDiagnosticPattern.FindStringSubmatch(line); match != nil {
+			diagnostics = append(diagnostics, TSCDiagnostic{
+				Severity: match[1],
+				Code:     match[2],
+				Message:  match[3],
+			})
+			continue
+		}
+
+		// Related information and message chains are indented below the diagnostic they belong to
+		if len(diagnostics) > 0 && strings.HasPrefix(line, " ") {
+			last := &diagnostics[len(diagnostics)-1]
+			last.Message += "\n" + line
+		}
+	}
+
+	return diagnostics
+}
@@ -0,0 +1,410 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StripJSONComments turns JSON with comments and trailing commas, as used by tsconfig.json, into
+// plain JSON. Strings are copied unchanged.
+func StripJSONComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			start := i
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			end := i + 1
+			if end > len(data) {
+				end = len(data)
+			}
+			out = append(out, data[start:end]...)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ',':
+			// Drop the comma when only whitespace and comments separate it from a closing bracket
+			if next := nextJSONToken(data, i+1); next == '}' || next == ']' {
+				continue
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// nextJSONToken returns the next character after whitespace and comments, or 0 at the end
+func nextJSONToken(data []byte, i int) byte {
+	for i < len(data) {
+		switch {
+		case data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r':
+			i++
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i += 2
+		default:
+			return data[i]
+		}
+	}
+	return 0
+}
+
+// ReadJSONC reads a JSON file that may contain comments and trailing commas.
+func ReadJSONC(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(StripJSONComments(data), v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// TsconfigExtends is the extends field of a tsconfig, either a single config or a list of them
+type TsconfigExtends []string
+
+func (e *TsconfigExtends) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*e = []string{s}
+		return nil
+	}
+
+	var l []string
+	if err := json.Unmarshal(data, &l); err != nil {
+		return fmt.Errorf("extends must be either a string or a list of strings")
+	}
+	*e = l
+	return nil
+}
+
+// GetTsconfigFiles returns a tsconfig and every config it extends, directly or indirectly. The
+// files read so far are returned along with an error.
+func GetTsconfigFiles(path string) ([]string, error) {
+	files := []string{}
+	seen := make(map[string]bool)
+
+	var visit func(path string) error
+	visit = func(path string) error {
+		path = filepath.Clean(path)
+		if seen[path] {
+			return nil
+		}
+		seen[path] = true
+		files = append(files, path)
+
+		var tsconfig struct {
+			Extends TsconfigExtends `json:"extends"`
+		}
+		if err := ReadJSONC(path, &tsconfig); err != nil {
+			return err
+		}
+
+		for _, extends := range tsconfig.Extends {
+			extendedPath, err := ResolveTsconfigExtends(filepath.Dir(path), extends)
+			if err != nil {
+				// Keep a missing relative config in the list, so it is picked up once it is created
+				if strings.HasPrefix(extends, ".") {
+					missing := filepath.Join(filepath.Dir(path), extends)
+					if !strings.HasSuffix(missing, ".json") {
+						missing += ".json"
+					}
+					files = append(files, missing)
+				}
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if err := visit(extendedPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := visit(path); err != nil {
+		return files, err
+	}
+	return files, nil
+}
+
+// ResolveTsconfigExtends resolves an extends specifier like tsc does: relative to the extending
+// config, or as a package in the node_modules directories above it.
+func ResolveTsconfigExtends(dir, extends string) (string, error) {
+	if strings.HasPrefix(extends, ".") || filepath.IsAbs(extends) {
+		path := extends
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, extends)
+		}
+		return tsconfigCandidate(path)
+	}
+
+	for current := dir; ; current = filepath.Dir(current) {
+		path, err := tsconfigCandidate(filepath.Join(current, "node_modules", extends))
+		if err == nil {
+			return path, nil
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+	}
+
+	return "", fmt.Errorf("cannot find extended tsconfig %q", extends)
+}
+
+// tsconfigCandidate finds the config a path refers to: the path itself, the path with a .json
+// extension, or the tsconfig.json of a directory or package.
+func tsconfigCandidate(path string) (string, error) {
+	candidates := []string{path}
+	if !strings.HasSuffix(path, ".json") {
+		candidates = append(candidates, path+".json")
+	}
+	candidates = append(candidates, filepath.Join(path, "tsconfig.json"))
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot find extended tsconfig %q", path)
+}
+
+// Tsconfig holds the options squish uses from a tsconfig, merged with the configs it extends.
+// Paths are absolute, resolved against the config that sets them.
+type Tsconfig struct {
+	Path    string
+	RootDir string
+	OutDir  string
+	BaseURL string
+	Paths   map[string][]string
+	// PathsDir is the directory of the config setting paths, which they are relative to without a baseUrl
+	PathsDir string
+	// References are the tsconfig files of the referenced projects, which are not inherited
+	References []string
+}
+
+type rawTsconfig struct {
+	Extends         TsconfigExtends `json:"extends"`
+	CompilerOptions struct {
+		RootDir *string             `json:"rootDir"`
+		OutDir  *string             `json:"outDir"`
+		BaseURL *string             `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+	References []struct {
+		Path string `json:"path"`
+	} `json:"references"`
+}
+
+// ReadTsconfig reads a tsconfig and the configs it extends, later configs in extends overriding
+// earlier ones and the config itself overriding all of them.
+func ReadTsconfig(path string) (*Tsconfig, error) {
+	return readTsconfig(path, make(map[string]bool))
+}
+
+func readTsconfig(path string, visiting map[string]bool) (*Tsconfig, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if visiting[path] {
+		return nil, fmt.Errorf("%s extends itself", path)
+	}
+	visiting[path] = true
+	defer delete(visiting, path)
+
+	var raw rawTsconfig
+	if err := ReadJSONC(path, &raw); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(path)
+	tsconfig := &Tsconfig{Path: path}
+	for _, extends := range raw.Extends {
+		extendedPath, err := ResolveTsconfigExtends(dir, extends)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		extended, err := readTsconfig(extendedPath, visiting)
+		if err != nil {
+			return nil, err
+		}
+		tsconfig.inherit(extended)
+	}
+
+	options := raw.CompilerOptions
+	if options.RootDir != nil {
+		tsconfig.RootDir = filepath.Join(dir, *options.RootDir)
+	}
+	if options.OutDir != nil {
+		tsconfig.OutDir = filepath.Join(dir, *options.OutDir)
+	}
+	if options.BaseURL != nil {
+		tsconfig.BaseURL = filepath.Join(dir, *options.BaseURL)
+	}
+	if options.Paths != nil {
+		tsconfig.Paths = options.Paths
+		tsconfig.PathsDir = dir
+	}
+
+	for _, reference := range raw.References {
+		referencePath := filepath.Join(dir, reference.Path)
+		if !strings.HasSuffix(referencePath, ".json") {
+			referencePath = filepath.Join(referencePath, "tsconfig.json")
+		}
+		tsconfig.References = append(tsconfig.References, referencePath)
+	}
+
+	return tsconfig, nil
+}
+
+// inherit takes the compiler options of an extended config
+func (t *Tsconfig) inherit(extended *Tsconfig) {
+	if extended.RootDir != "" {
+		t.RootDir = extended.RootDir
+	}
+	if extended.OutDir != "" {
+		t.OutDir = extended.OutDir
+	}
+	if extended.BaseURL != "" {
+		t.BaseURL = extended.BaseURL
+	}
+	if extended.Paths != nil {
+		t.Paths = extended.Paths
+		t.PathsDir = extended.PathsDir
+	}
+}
+
+// tsconfigResolveExtensions are the extensions tried for a file a paths mapping points at
+var tsconfigResolveExtensions = []string{".ts", ".tsx", ".d.ts", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"}
+
+// ResolveAlias resolves a non-relative import through paths and baseUrl like tsc does: the
+// pattern with the longest prefix before its "*" wins and its targets are tried in order. It
+// returns the file the import refers to.
+func (t *Tsconfig) ResolveAlias(specifier string) (string, bool) {
+	if strings.HasPrefix(specifier, ".") || filepath.IsAbs(specifier) {
+		return "", false
+	}
+
+	base := t.BaseURL
+	if base == "" {
+		base = t.PathsDir
+	}
+
+	bestPattern, bestWildcard, bestPrefix := "", "", -1
+	for pattern := range t.Paths {
+		prefix, suffix, hasWildcard := strings.Cut(pattern, "*")
+		switch {
+		case !hasWildcard && pattern == specifier:
+			// An exact match wins over every pattern
+			bestPattern, bestWildcard, bestPrefix = pattern, "", len(specifier)+1
+		case hasWildcard && len(prefix) > bestPrefix && len(specifier) >= len(prefix)+len(suffix) &&
+			strings.HasPrefix(specifier, prefix) && strings.HasSuffix(specifier, suffix):
+			bestPattern, bestWildcard, bestPrefix = pattern, specifier[len(prefix):len(specifier)-len(suffix)], len(prefix)
+		}
+	}
+	if bestPrefix >= 0 {
+		for _, target := range t.Paths[bestPattern] {
+			if path, ok := resolveTsconfigFile(filepath.Join(base, strings.Replace(target, "*", bestWildcard, 1))); ok {
+				return path, true
+			}
+		}
+	}
+
+	if t.BaseURL != "" {
+		return resolveTsconfigFile(filepath.Join(t.BaseURL, specifier))
+	}
+	return "", false
+}
+
+// resolveTsconfigFile finds the file a path without extension or a directory refers to.
+func resolveTsconfigFile(path string) (string, bool) {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return path, true
+	}
+	for _, ext := range tsconfigResolveExtensions {
+		if FileExists(path + ext) {
+			return path + ext, true
+		}
+	}
+	for _, ext := range tsconfigResolveExtensions {
+		if index := filepath.Join(path, "index"+ext); FileExists(index) {
+			return index, true
+		}
+	}
+	return "", false
+}
+
+// GetTsconfigReferences returns the tsconfig files of every project a tsconfig references,
+// directly or indirectly, ordered so projects come after the projects they reference.
+func GetTsconfigReferences(path string) ([]string, error) {
+	ordered := []string{}
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(path string, root bool) error
+	visit = func(path string, root bool) error {
+		if done[path] {
+			return nil
+		}
+		if visiting[path] {
+			return fmt.Errorf("project references form a cycle through %s", path)
+		}
+		visiting[path] = true
+
+		tsconfig, err := ReadTsconfig(path)
+		if err != nil {
+			return err
+		}
+		for _, reference := range tsconfig.References {
+			if err := visit(reference, false); err != nil {
+				return err
+			}
+		}
+
+		visiting[path] = false
+		done[path] = true
+		if !root {
+			ordered = append(ordered, path)
+		}
+		return nil
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := visit(absPath, true); err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
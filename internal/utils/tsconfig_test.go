@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"squish/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestStripJSONComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "plain JSON", input: `{"a": [1, 2]}`, want: `{"a": [1, 2]}`},
+		{name: "line comment", input: "{\n  // comment\n  \"a\": 1 // trailing\n}", want: "{\n  \n  \"a\": 1 \n}"},
+		{name: "block comment", input: `{/* a */"a": /* b */1}`, want: `{"a": 1}`},
+		{name: "comment markers in strings", input: `{"a": "// not a comment", "b": "/* nor this */"}`, want: `{"a": "// not a comment", "b": "/* nor this */"}`},
+		{name: "escaped quote", input: `{"a": "say \"hi\" // there"}`, want: `{"a": "say \"hi\" // there"}`},
+		{name: "trailing commas", input: `{"a": [1, 2,], "b": {"c": 3,},}`, want: `{"a": [1, 2], "b": {"c": 3}}`},
+		{name: "trailing comma before comment", input: "{\"a\": 1, // last\n}", want: "{\"a\": 1 \n}"},
+		{name: "comma in string", input: `{"a": ",}"}`, want: `{"a": ",}"}`},
+		{name: "unterminated string", input: `{"a": "b`, want: `{"a": "b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(StripJSONComments([]byte(tt.input)))
+			if got != tt.want {
+				t.Errorf("StripJSONComments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadTsconfig(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"tsconfig.base.json":                          `{"compilerOptions": {"rootDir": "src", "outDir": "build", "paths": {"@/*": ["src/*"]}}}`,
+		"node_modules/@tsconfig/strict/tsconfig.json": `{"compilerOptions": {"outDir": "ignored"}}`,
+		"packages/a/tsconfig.json": `{
+			// Later configs in extends override earlier ones
+			"extends": ["@tsconfig/strict", "../../tsconfig.base"],
+			"compilerOptions": {"outDir": "dist",},
+			"references": [{"path": "../b"}, {"path": "../c/tsconfig.build.json"}],
+		}`,
+	})
+
+	tsconfig, err := ReadTsconfig(filepath.Join(dir, "packages/a/tsconfig.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Tsconfig{
+		Path:       filepath.Join(dir, "packages/a/tsconfig.json"),
+		RootDir:    filepath.Join(dir, "src"),
+		OutDir:     filepath.Join(dir, "packages/a/dist"),
+		Paths:      map[string][]string{"@/*": {"src/*"}},
+		PathsDir:   dir,
+		References: []string{filepath.Join(dir, "packages/b/tsconfig.json"), filepath.Join(dir, "packages/c/tsconfig.build.json")},
+	}
+	if !reflect.DeepEqual(tsconfig, want) {
+		t.Errorf("ReadTsconfig() = %+v, want %+v", tsconfig, want)
+	}
+
+	testutil.WriteFiles(t, dir, map[string]string{
+		"loop/a.json": `{"extends": "./b.json"}`,
+		"loop/b.json": `{"extends": "./a.json"}`,
+	})
+	if _, err := ReadTsconfig(filepath.Join(dir, "loop/a.json")); err == nil || !strings.Contains(err.Error(), "extends itself") {
+		t.Errorf("error = %v, want a cycle through extends", err)
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"src/utils/string.ts":       ``,
+		"src/utils/index.ts":        ``,
+		"src/components/button.tsx": ``,
+		"src/config.ts":             ``,
+		"src/env.d.ts":              ``,
+		"lib/legacy.js":             ``,
+		"generated/api.ts":          ``,
+	})
+
+	paths := map[string][]string{
+		"@/*":         {"src/*"},
+		"@/utils/*":   {"missing/*", "src/utils/*"},
+		"#config":     {"src/config"},
+		"~*":          {"generated/*"},
+		"legacy/*.js": {"lib/*.js"},
+	}
+
+	tests := []struct {
+		name      string
+		tsconfig  Tsconfig
+		specifier string
+		want      string
+		wantOK    bool
+	}{
+		{name: "pattern", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "@/components/button", want: "src/components/button.tsx", wantOK: true},
+		{name: "longest prefix with fallback target", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "@/utils/string", want: "src/utils/string.ts", wantOK: true},
+		{name: "directory index", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "@/utils", want: "src/utils/index.ts", wantOK: true},
+		{name: "declaration file", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "@/env", want: "src/env.d.ts", wantOK: true},
+		{name: "exact match", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "#config", want: "src/config.ts", wantOK: true},
+		{name: "suffix", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "legacy/legacy.js", want: "lib/legacy.js", wantOK: true},
+		{name: "empty prefix", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "~api", want: "generated/api.ts", wantOK: true},
+		{name: "paths relative to baseUrl", tsconfig: Tsconfig{Paths: map[string][]string{"@/*": {"*"}}, PathsDir: dir, BaseURL: filepath.Join(dir, "src")}, specifier: "@/config", want: "src/config.ts", wantOK: true},
+		{name: "baseUrl", tsconfig: Tsconfig{BaseURL: filepath.Join(dir, "src")}, specifier: "utils/string", want: "src/utils/string.ts", wantOK: true},
+		{name: "unresolved pattern", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "@/missing", wantOK: false},
+		{name: "package", tsconfig: Tsconfig{Paths: paths, PathsDir: dir}, specifier: "react", wantOK: false},
+		{name: "relative import", tsconfig: Tsconfig{BaseURL: filepath.Join(dir, "src")}, specifier: "./config", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.tsconfig.ResolveAlias(tt.specifier)
+			want := ""
+			if tt.wantOK {
+				want = filepath.Join(dir, filepath.FromSlash(tt.want))
+			}
+			if ok != tt.wantOK || got != want {
+				t.Errorf("ResolveAlias(%q) = %q, %v, want %q, %v", tt.specifier, got, ok, want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGetTsconfigReferences(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"tsconfig.json":                `{"references": [{"path": "./packages/app"}, {"path": "./packages/utils"}]}`,
+		"packages/app/tsconfig.json":   `{"references": [{"path": "../core"}, {"path": "../utils"}]}`,
+		"packages/core/tsconfig.json":  `{"references": [{"path": "../utils/tsconfig.json"}]}`,
+		"packages/utils/tsconfig.json": `{}`,
+	})
+
+	references, err := GetTsconfigReferences(filepath.Join(dir, "tsconfig.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "packages/utils/tsconfig.json"),
+		filepath.Join(dir, "packages/core/tsconfig.json"),
+		filepath.Join(dir, "packages/app/tsconfig.json"),
+	}
+	if !slices.Equal(references, want) {
+		t.Errorf("GetTsconfigReferences() = %q, want %q", references, want)
+	}
+
+	testutil.WriteFiles(t, dir, map[string]string{
+		"cycle/a/tsconfig.json": `{"references": [{"path": "../b"}]}`,
+		"cycle/b/tsconfig.json": `{"references": [{"path": "../a"}]}`,
+	})
+	if _, err := GetTsconfigReferences(filepath.Join(dir, "cycle/a/tsconfig.json")); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("error = %v, want a cycle through the references", err)
+	}
+}
@@ -0,0 +1,132 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"squish/internal/utils"
+	"sync"
+	"time"
+)
+
+// Process runs a command after successful builds in watch mode, e.g. to restart a server once
+// its bundle is rebuilt. The running command is stopped before it is started again.
+type Process struct {
+	command string
+	// timeout is how long a stopping command may take to exit before it is killed
+	timeout time.Duration
+	cmd     *exec.Cmd
+	exited  chan struct{}
+	mu      sync.Mutex
+}
+
+func NewProcess(command string, timeout time.Duration) *Process {
+	return &Process{
+		command: command,
+		timeout: timeout,
+	}
+}
+
+// Restart stops the running command, if any, and starts it again.
+func (p *Process) Restart() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd != nil {
+		utils.Log("Restarting: ", p.command)
+		p.stop(terminateSignal)
+	} else {
+		utils.Log("Starting: ", p.command)
+	}
+
+	// The command runs in its own process group, in the background of the terminal, where reading
+	// the terminal would stop it, so its stdin is the null device
+	cmd := shellCommand(p.command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		close(exited)
+
+		// Exits of commands being stopped are expected and not reported
+		p.mu.Lock()
+		current := p.cmd == cmd
+		p.mu.Unlock()
+		if !current {
+			return
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			utils.Log("Command exited with code ", exitErr.ExitCode(), ": ", p.command)
+		} else if err == nil {
+			utils.Log("Command exited: ", p.command)
+		}
+	}()
+
+	p.cmd = cmd
+	p.exited = exited
+	return nil
+}
+
+// Stop forwards a signal to the running command and waits for it to exit, killing it once the
+// timeout has passed.
+func (p *Process) Stop(sig os.Signal) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd != nil {
+		p.stop(sig)
+	}
+}
+
+// stop signals the running command, killing it if it does not exit in time. The lock must be held.
+func (p *Process) stop(sig os.Signal) {
+	cmd, exited := p.cmd, p.exited
+	p.cmd, p.exited = nil, nil
+
+	select {
+	case <-exited:
+		return
+	default:
+	}
+
+	if err := signalProcess(cmd, sig); err != nil {
+		utils.Log("Error stopping command: ", err)
+	}
+
+	timeout := time.NewTimer(p.timeout)
+	defer timeout.Stop()
+
+	select {
+	case <-exited:
+	case <-timeout.C:
+		p.kill(cmd)
+		<-exited
+		return
+	}
+
+	// The shell may exit before the processes it started
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for processGroupAlive(cmd) {
+		select {
+		case <-ticker.C:
+		case <-timeout.C:
+			p.kill(cmd)
+			return
+		}
+	}
+}
+
+func (p *Process) kill(cmd *exec.Cmd) {
+	utils.Log("Command did not exit within ", p.timeout, ", killing it")
+	if err := killProcess(cmd); err != nil {
+		utils.Log("Error killing command: ", err)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+
+package watcher
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var terminateSignal os.Signal = syscall.SIGTERM
+
+// shellCommand runs a command through the shell in its own process group, so signals reach
+// every process it starts.
+func shellCommand(command string) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+func signalProcess(cmd *exec.Cmd, sig os.Signal) error {
+	signal, ok := sig.(syscall.Signal)
+	if !ok {
+		signal = syscall.SIGTERM
+	}
+	return syscall.Kill(-cmd.Process.Pid, signal)
+}
+
+func killProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// processGroupAlive reports whether any process started by the command is still running.
+func processGroupAlive(cmd *exec.Cmd) bool {
+	return syscall.Kill(-cmd.Process.Pid, 0) == nil
+}
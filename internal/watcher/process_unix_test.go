@@ -0,0 +1,63 @@
+//go:build !windows
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForFile returns the contents of a file once a command wrote lines lines to it
+func waitForFile(t *testing.T, path string, lines int) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(path); err == nil && strings.Count(string(data), "\n") >= lines {
+			return string(data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s was not written", path)
+	return ""
+}
+
+func TestProcessStdin(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	// Reading stdin ends right away instead of stopping the command
+	p := NewProcess(`cat; echo read > `+out, time.Second)
+	if err := p.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop(terminateSignal)
+
+	if got := waitForFile(t, out, 1); got != "read\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestProcessRestart(t *testing.T) {
+	dir := t.TempDir()
+	started := filepath.Join(dir, "started")
+	stopped := filepath.Join(dir, "stopped")
+	p := NewProcess(`trap 'echo stopped >> `+stopped+`; exit 0' TERM; echo started >> `+started+`; while true; do sleep 0.05; done`, 5*time.Second)
+
+	if err := p.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	waitForFile(t, started, 1)
+	if err := p.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitForFile(t, stopped, 1); got != "stopped\n" {
+		t.Errorf("stopped = %q", got)
+	}
+	waitForFile(t, started, 2)
+
+	p.Stop(terminateSignal)
+	if got := waitForFile(t, stopped, 2); got != "stopped\nstopped\n" {
+		t.Errorf("stopped = %q", got)
+	}
+}
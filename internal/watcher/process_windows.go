@@ -0,0 +1,27 @@
+//go:build windows
+
+package watcher
+
+import (
+	"os"
+	"os/exec"
+)
+
+var terminateSignal os.Signal = os.Kill
+
+func shellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// signalProcess kills the command, as Windows cannot deliver other signals to a process
+func signalProcess(cmd *exec.Cmd, sig os.Signal) error {
+	return cmd.Process.Kill()
+}
+
+func killProcess(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func processGroupAlive(cmd *exec.Cmd) bool {
+	return false
+}
@@ -1,28 +1,66 @@
 package watcher
 
 import (
+	"errors"
 	"github.com/fsnotify/fsnotify"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"squish/internal/utils"
 	"squish/pkg/esbuild"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// ReloadFunc re-reads the configuration, reconfigures the bundler with it and returns the
+// configuration files to watch from then on
+type ReloadFunc func() ([]string, error)
+
 type Watcher struct {
 	bundler *esbuild.Bundler
-	srcDir  string
+	reload  ReloadFunc
+	// onSuccess is restarted after every successful build, nil without --on-success
+	onSuccess *Process
+	srcDir    string
+	// dirs are the absolute paths of the source directories currently watched
+	dirs map[string]bool
+	// configFiles are the absolute paths of the configuration files, watched through their directories
+	configFiles map[string]bool
+	configDirs  map[string]bool
+	// reloadPending is set when a configuration file changed since the last build
+	reloadPending bool
+	// fullBuildPending is set when the next build has to build every entry
+	fullBuildPending bool
+	// changed are the absolute paths of the source files and directories changed since the last build
+	changed map[string]bool
+	mu      sync.Mutex
 }
 
-func NewWatcher(bundler *esbuild.Bundler, srcDir string) *Watcher {
-	return &Watcher{
-		bundler: bundler,
-		srcDir:  srcDir,
+func NewWatcher(bundler *esbuild.Bundler, configFiles []string, reload ReloadFunc, onSuccess *Process) *Watcher {
+	w := &Watcher{
+		bundler:     bundler,
+		reload:      reload,
+		onSuccess:   onSuccess,
+		srcDir:      bundler.Config().SrcDir,
+		dirs:        make(map[string]bool),
+		configFiles: make(map[string]bool),
+		configDirs:  make(map[string]bool),
+		// The first build builds every entry
+		fullBuildPending: true,
+		changed:          make(map[string]bool),
+	}
+	for _, file := range configFiles {
+		w.configFiles[absPath(file)] = true
 	}
+	return w
 }
 
+// rebuildOps are the file operations that can change the build, including renames editors use
+// to save files atomically
+const rebuildOps = fsnotify.Write | fsnotify.Create | fsnotify.Remove | fsnotify.Rename
+
 func (w *Watcher) Watch() error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -30,10 +68,14 @@ func (w *Watcher) Watch() error {
 	}
 	defer watcher.Close()
 
-	var rebuildTimer *time.Timer
-	debounceDuration := 100 * time.Millisecond
+	// Directories are added before events are handled, which update the watched set concurrently
+	if err := w.addDirs(watcher, w.srcDir); err != nil {
+		return err
+	}
+	w.watchConfigDirs(watcher)
 
-	done := make(chan bool)
+	// changes holds at most one notification, as any number of changes lead to a single build
+	changes := make(chan struct{}, 1)
 	go func() {
 		for {
 			select {
@@ -41,16 +83,12 @@ func (w *Watcher) Watch() error {
 				if !ok {
 					return
 				}
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					if rebuildTimer != nil {
-						rebuildTimer.Stop()
-					}
-					rebuildTimer = time.AfterFunc(debounceDuration, func() {
-						utils.Log("Detected changes, rebuilding...")
-						if err := w.bundler.Bundle(); err != nil {
-							utils.Log("Error bundling:", err)
-						}
-					})
+				if event.Op&rebuildOps == 0 || !w.handleEvent(watcher, event) {
+					continue
+				}
+				select {
+				case changes <- struct{}{}:
+				default:
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -61,30 +99,310 @@ func (w *Watcher) Watch() error {
 		}
 	}()
 
-	err = filepath.Walk(w.srcDir, func(path string, info os.FileInfo, err error) error {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		w.buildLoop(watcher, changes, stop)
+		close(stopped)
+	}()
+
+	// Setup signal handling for graceful shutdown
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	utils.Log("Watching for changes in", w.srcDir)
+	sig := <-signals
+	utils.Log("Received interrupt signal, shutting down watcher...")
+	close(stop)
+	<-stopped
+	if w.onSuccess != nil {
+		w.onSuccess.Stop(sig)
+	}
+	w.bundler.Dispose()
+	return nil
+}
+
+// debounceDuration is how long changes have to settle before a rebuild starts
+const debounceDuration = 100 * time.Millisecond
+
+// buildLoop runs every build of the watcher, so at most one build runs at a time. Changes during
+// a build cancel it and are coalesced into a single follow-up build.
+func (w *Watcher) buildLoop(watcher *fsnotify.Watcher, changes <-chan struct{}, stop <-chan struct{}) {
+	initial := true
+	dirty := false
+
+	for {
+		if !initial {
+			if !dirty {
+				select {
+				case <-changes:
+				case <-stop:
+					return
+				}
+			}
+			if !waitForQuiet(changes, stop) {
+				return
+			}
+		}
+
+		finished := make(chan struct{})
+		go func(initial bool) {
+			w.rebuild(watcher, initial)
+			close(finished)
+		}(initial)
+		initial, dirty = false, false
+
+	building:
+		for {
+			select {
+			case <-finished:
+				break building
+			case <-changes:
+				if !dirty {
+					dirty = true
+					w.bundler.Cancel()
+				}
+			case <-stop:
+				w.bundler.Cancel()
+				<-finished
+				return
+			}
+		}
+	}
+}
+
+// waitForQuiet waits until no change has been reported for the debounce duration. It returns
+// false when the watcher is stopped first.
+func waitForQuiet(changes <-chan struct{}, stop <-chan struct{}) bool {
+	timer := time.NewTimer(debounceDuration)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-changes:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(debounceDuration)
+		case <-timer.C:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// rebuild reloads the configuration if one of its files changed and rebuilds the entries affected
+// by the changes, or every entry after a configuration change.
+func (w *Watcher) rebuild(watcher *fsnotify.Watcher, initial bool) {
+	w.mu.Lock()
+	reload, full, changed := w.reloadPending, w.fullBuildPending, w.changed
+	w.reloadPending, w.fullBuildPending, w.changed = false, false, make(map[string]bool)
+	w.mu.Unlock()
+
+	if reload {
+		utils.Log("Configuration changed, reloading...")
+		configFiles, err := w.reload()
 		if err != nil {
-			return err
+			utils.Log("Error reloading configuration:", err)
+			// The changes are built once the configuration is fixed
+			w.requeue(full, changed)
+			return
 		}
-		if info.IsDir() {
-			return watcher.Add(path)
+		w.setConfigFiles(watcher, configFiles)
+		w.setSrcDir(watcher, w.bundler.Config().SrcDir)
+		full = true
+	}
+
+	if !initial {
+		utils.Log("Detected changes, rebuilding...")
+	}
+
+	if w.build(full, changed) {
+		// The changes are built again by the build that follows a canceled one
+		w.requeue(full, changed)
+	}
+}
+
+// requeue puts changes that were not built back, so the next rebuild includes them.
+func (w *Watcher) requeue(full bool, changed map[string]bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fullBuildPending = w.fullBuildPending || full
+	for path := range changed {
+		w.changed[path] = true
+	}
+}
+
+// build bundles the package and restarts the --on-success command if the build succeeded. After
+// a failed build the command keeps running the previous bundle. It reports whether the build was
+// canceled.
+func (w *Watcher) build(full bool, changed map[string]bool) bool {
+	rebuilt := true
+	var err error
+	if full {
+		err = w.bundler.Bundle()
+	} else {
+		paths := make([]string, 0, len(changed))
+		for path := range changed {
+			paths = append(paths, path)
 		}
-		return nil
-	})
+		rebuilt, err = w.bundler.Rebuild(paths)
+	}
+
 	if err != nil {
-		return err
+		if errors.Is(err, esbuild.ErrBuildCanceled) {
+			utils.Log("Build canceled, changes detected during the build")
+			return true
+		}
+		utils.Log("Error bundling:", err)
+		return false
 	}
 
-	// Setup signal handling for graceful shutdown
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	if !rebuilt {
+		utils.Log("No entries are affected by the changes")
+		return false
+	}
 
-	go func() {
-		<-signals
-		utils.Log("Received interrupt signal, shutting down watcher...")
-		close(done)
-	}()
+	if w.onSuccess != nil {
+		if err := w.onSuccess.Restart(); err != nil {
+			utils.Log("Error running command:", err)
+		}
+	}
+	return false
+}
 
-	utils.Log("Watching for changes in", w.srcDir)
-	<-done
-	return nil
+// handleEvent updates the watched directories and reports whether the event changes the build:
+// a change to a configuration file or to a file in the source directory.
+func (w *Watcher) handleEvent(watcher *fsnotify.Watcher, event fsnotify.Event) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	path := absPath(event.Name)
+	if w.configFiles[path] {
+		w.reloadPending = true
+		return true
+	}
+
+	if !w.dirs[filepath.Dir(path)] && !w.dirs[path] {
+		return false
+	}
+	w.changed[path] = true
+
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			if err := w.addDirs(watcher, path); err != nil {
+				utils.Log("Error watching ", path, ": ", err)
+			}
+		}
+	}
+
+	if (event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)) && w.dirs[path] {
+		w.removeDirs(watcher, path)
+	}
+
+	return true
+}
+
+// setConfigFiles replaces the watched configuration files, e.g. after a tsconfig starts extending
+// another one.
+func (w *Watcher) setConfigFiles(watcher *fsnotify.Watcher, configFiles []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.configFiles = make(map[string]bool)
+	for _, file := range configFiles {
+		w.configFiles[absPath(file)] = true
+	}
+
+	for dir := range w.configDirs {
+		if !w.isConfigDir(dir) && !w.dirs[dir] {
+			_ = watcher.Remove(dir)
+			delete(w.configDirs, dir)
+		}
+	}
+	w.watchConfigDirs(watcher)
+}
+
+// setSrcDir moves the watch to a new source directory.
+func (w *Watcher) setSrcDir(watcher *fsnotify.Watcher, srcDir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if absPath(srcDir) == absPath(w.srcDir) {
+		return
+	}
+
+	w.removeDirs(watcher, absPath(w.srcDir))
+	w.srcDir = srcDir
+	if err := w.addDirs(watcher, srcDir); err != nil {
+		utils.Log("Error watching ", srcDir, ": ", err)
+	}
+}
+
+// watchConfigDirs watches the directories of the configuration files. Files are watched through
+// their directory, as editors replace files on save and files may not exist yet.
+func (w *Watcher) watchConfigDirs(watcher *fsnotify.Watcher) {
+	for file := range w.configFiles {
+		dir := filepath.Dir(file)
+		if w.configDirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			utils.Log("Error watching ", dir, ": ", err)
+			continue
+		}
+		w.configDirs[dir] = true
+	}
+}
+
+func (w *Watcher) isConfigDir(dir string) bool {
+	for file := range w.configFiles {
+		if filepath.Dir(file) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// addDirs watches a directory and all directories below it.
+func (w *Watcher) addDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(absPath(root), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		if w.dirs[path] {
+			return nil
+		}
+		if err := watcher.Add(path); err != nil {
+			return err
+		}
+		w.dirs[path] = true
+		return nil
+	})
+}
+
+// removeDirs stops watching a directory and all directories below it.
+func (w *Watcher) removeDirs(watcher *fsnotify.Watcher, root string) {
+	prefix := root + string(filepath.Separator)
+	for dir := range w.dirs {
+		if dir == root || strings.HasPrefix(dir, prefix) {
+			// The watch of a deleted directory is already gone, so errors are expected here
+			if !w.configDirs[dir] {
+				_ = watcher.Remove(dir)
+			}
+			delete(w.dirs, dir)
+		}
+	}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
 }
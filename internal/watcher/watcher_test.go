@@ -0,0 +1,37 @@
+package watcher
+
+import (
+	"errors"
+	"squish/internal/config"
+	"squish/pkg/esbuild"
+	"testing"
+)
+
+func TestRebuildKeepsChangesWhenReloadFails(t *testing.T) {
+	bundler := esbuild.NewBundler(&esbuild.BundlerConfig{SrcDir: t.TempDir()}, &config.PackageJSON{})
+	w := NewWatcher(bundler, nil, func() ([]string, error) {
+		return nil, errors.New("invalid configuration")
+	}, nil)
+
+	w.fullBuildPending = false
+	w.reloadPending = true
+	w.changed["/src/index.ts"] = true
+	w.rebuild(nil, false)
+
+	if !w.changed["/src/index.ts"] {
+		t.Errorf("changed = %v, want the changed source file kept", w.changed)
+	}
+	if w.fullBuildPending {
+		t.Errorf("fullBuildPending = true, want false")
+	}
+	if w.reloadPending {
+		t.Errorf("reloadPending = true, want the reload to wait for the next configuration change")
+	}
+
+	w.fullBuildPending = true
+	w.reloadPending = true
+	w.rebuild(nil, false)
+	if !w.fullBuildPending {
+		t.Errorf("fullBuildPending = false, want the pending full build kept")
+	}
+}
@@ -0,0 +1,143 @@
+package workspace
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"squish/internal/utils"
+)
+
+// BuildFunc builds a package, writing its log output to w so output of concurrent builds does
+// not interleave
+type BuildFunc func(p *Package, w io.Writer) error
+
+// buildResult is a finished build of a package
+type buildResult struct {
+	pkg    *Package
+	output bytes.Buffer
+	err    error
+}
+
+// Build builds the packages in dependency order: a package is built once the packages it depends
+// on that are being built have been built, and independent packages are built in parallel, at
+// most concurrency at a time. The output of a build is printed once it finishes. When a build
+// fails the packages depending on it are skipped, the others are still built, and the errors of
+// every failed build are returned together.
+func Build(packages []*Package, concurrency int, build BuildFunc) error {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	included := make(map[*Package]bool, len(packages))
+	for _, p := range packages {
+		included[p] = true
+	}
+
+	// waiting counts the dependencies of a package that have not been built yet
+	waiting := make(map[*Package]int, len(packages))
+	ready := []*Package{}
+	for _, p := range packages {
+		for _, dep := range p.Dependencies {
+			if included[dep] {
+				waiting[p]++
+			}
+		}
+		if waiting[p] == 0 {
+			ready = append(ready, p)
+		}
+	}
+
+	results := make(chan *buildResult)
+	skipped := make(map[*Package]bool)
+	errs := []error{}
+	running, finished := 0, 0
+
+	for finished < len(packages) {
+		for running < concurrency && len(ready) > 0 {
+			p := ready[0]
+			ready = ready[1:]
+			running++
+			go func(p *Package) {
+				result := &buildResult{pkg: p}
+				result.err = build(p, &result.output)
+				results <- result
+			}(p)
+		}
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+		finished++
+		os.Stdout.Write(result.output.Bytes())
+
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.pkg.Name, result.err))
+			for _, dependent := range skipDependents(result.pkg, included, skipped) {
+				utils.Log("Skipping ", dependent.Name, ", it depends on ", result.pkg.Name, " which failed to build")
+				finished++
+			}
+			continue
+		}
+
+		for _, dependent := range result.pkg.Dependents {
+			if !included[dependent] || skipped[dependent] {
+				continue
+			}
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// skipDependents marks the packages depending on p, directly or indirectly, as skipped and
+// returns the ones that were not skipped before.
+func skipDependents(p *Package, included, skipped map[*Package]bool) []*Package {
+	result := []*Package{}
+	for _, dependent := range p.Dependents {
+		if !included[dependent] || skipped[dependent] {
+			continue
+		}
+		skipped[dependent] = true
+		result = append(result, dependent)
+		result = append(result, skipDependents(dependent, included, skipped)...)
+	}
+	return result
+}
+
+// Order returns the packages in an order they can be built one after another in, each after the
+// packages it depends on.
+func Order(packages []*Package) []*Package {
+	included := make(map[*Package]bool, len(packages))
+	for _, p := range packages {
+		included[p] = true
+	}
+
+	ordered := []*Package{}
+	visited := make(map[*Package]bool, len(packages))
+	var visit func(p *Package)
+	visit = func(p *Package) {
+		if visited[p] {
+			return
+		}
+		visited[p] = true
+		for _, dep := range p.Dependencies {
+			if included[dep] {
+				visit(dep)
+			}
+		}
+		ordered = append(ordered, p)
+	}
+	for _, p := range packages {
+		visit(p)
+	}
+	return ordered
+}
@@ -0,0 +1,136 @@
+package workspace
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOrder(t *testing.T) {
+	w := newWorkspace(t.TempDir(), map[string][]string{
+		"app":  {"ui", "utils"},
+		"core": {"utils"},
+		"ui":   {"core"},
+	}, "app", "core", "ui", "utils")
+
+	tests := []struct {
+		name     string
+		packages []string
+		want     []string
+	}{
+		{name: "all", packages: []string{"app", "core", "ui", "utils"}, want: []string{"utils", "core", "ui", "app"}},
+		{name: "subset", packages: []string{"core", "ui"}, want: []string{"core", "ui"}},
+		{name: "subset in reverse order", packages: []string{"app", "ui"}, want: []string{"ui", "app"}},
+		{name: "independent", packages: []string{"ui", "utils"}, want: []string{"ui", "utils"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages, err := w.Filter(tt.packages)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(Order(packages)); !slices.Equal(got, tt.want) {
+				t.Errorf("Order() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	// app depends on ui and utils, ui depends on core, core depends on utils, docs is independent
+	w := newWorkspace(t.TempDir(), map[string][]string{
+		"app":  {"ui", "utils"},
+		"core": {"utils"},
+		"ui":   {"core"},
+	}, "app", "core", "docs", "ui", "utils")
+
+	tests := []struct {
+		name        string
+		packages    []string
+		concurrency int
+		failing     []string
+		wantBuilt   []string
+		wantErrs    []string
+	}{
+		{
+			name:        "dependency order",
+			packages:    []string{"app", "core", "docs", "ui", "utils"},
+			concurrency: 1,
+			wantBuilt:   []string{"docs", "utils", "core", "ui", "app"},
+		},
+		{
+			name:        "dependencies outside the selection",
+			packages:    []string{"app", "ui"},
+			concurrency: 1,
+			wantBuilt:   []string{"ui", "app"},
+		},
+		{
+			name:        "failed build skips dependents",
+			packages:    []string{"app", "core", "docs", "ui", "utils"},
+			concurrency: 1,
+			failing:     []string{"core"},
+			wantBuilt:   []string{"docs", "utils", "core"},
+			wantErrs:    []string{"core: failed"},
+		},
+		{
+			name:        "failed builds are reported together",
+			packages:    []string{"app", "core", "docs", "ui", "utils"},
+			concurrency: 1,
+			failing:     []string{"docs", "utils"},
+			wantBuilt:   []string{"docs", "utils"},
+			wantErrs:    []string{"docs: failed", "utils: failed"},
+		},
+		{
+			name:        "parallel",
+			packages:    []string{"app", "core", "docs", "ui", "utils"},
+			concurrency: 4,
+			wantBuilt:   []string{"app", "core", "docs", "ui", "utils"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages, err := w.Filter(tt.packages)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var mu sync.Mutex
+			built := []*Package{}
+			err = Build(packages, tt.concurrency, func(p *Package, _ io.Writer) error {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, dep := range p.Dependencies {
+					if slices.Contains(packages, dep) && !slices.Contains(built, dep) {
+						t.Errorf("%s was built before its dependency %s", p.Name, dep.Name)
+					}
+				}
+				built = append(built, p)
+				if slices.Contains(tt.failing, p.Name) {
+					return errors.New("failed")
+				}
+				return nil
+			})
+
+			got := names(built)
+			if tt.concurrency > 1 {
+				slices.Sort(got)
+			}
+			if !slices.Equal(got, tt.wantBuilt) {
+				t.Errorf("built %q, want %q", got, tt.wantBuilt)
+			}
+
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if err == nil || err.Error() != strings.Join(tt.wantErrs, "\n") {
+				t.Errorf("error = %v, want %q", err, tt.wantErrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package workspace
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"squish/internal/config"
+	"squish/internal/utils"
+	"strings"
+)
+
+// Filter selects the packages matching any of the filters, in the order of w.Packages. A filter is
+// a package name, a glob of names like "@scope/*", or a glob of directories relative to the root
+// starting with "./". "name..." also selects the packages it depends on and "...name" the packages
+// depending on it, directly or indirectly. Without filters every package is selected.
+func (w *Workspace) Filter(filters []string) ([]*Package, error) {
+	if len(filters) == 0 {
+		return w.Packages, nil
+	}
+
+	selected := make(map[*Package]bool)
+	for _, filter := range filters {
+		pattern, dependencies := strings.CutSuffix(filter, "...")
+		pattern, dependents := strings.CutPrefix(pattern, "...")
+		if pattern == "" {
+			return nil, fmt.Errorf("invalid filter %q", filter)
+		}
+
+		matched := false
+		for _, p := range w.Packages {
+			if !w.matchFilter(pattern, p) {
+				continue
+			}
+			matched = true
+			selected[p] = true
+			if dependencies {
+				walk(p, func(p *Package) []*Package { return p.Dependencies }, selected, make(map[*Package]bool))
+			}
+			if dependents {
+				walk(p, func(p *Package) []*Package { return p.Dependents }, selected, make(map[*Package]bool))
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no workspace package matches the filter %q", filter)
+		}
+	}
+
+	packages := []*Package{}
+	for _, p := range w.Packages {
+		if selected[p] {
+			packages = append(packages, p)
+		}
+	}
+	return packages, nil
+}
+
+func (w *Workspace) matchFilter(pattern string, p *Package) bool {
+	if strings.HasPrefix(pattern, "./") {
+		return config.MatchGlob(cleanPattern(pattern), filepath.ToSlash(utils.RelOrAbs(w.Root, p.Dir)))
+	}
+	return matchName(pattern, p.Name)
+}
+
+// matchName matches a package name against a glob in which "*" matches any characters, including
+// the slash of scoped names.
+func matchName(pattern, name string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == name
+	}
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(name)
+}
+
+// walk adds the packages reachable from p through next to selected.
+func walk(p *Package, next func(*Package) []*Package, selected, visited map[*Package]bool) {
+	for _, n := range next(p) {
+		if !visited[n] {
+			visited[n] = true
+			selected[n] = true
+			walk(n, next, selected, visited)
+		}
+	}
+}
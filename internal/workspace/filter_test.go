@@ -0,0 +1,77 @@
+package workspace
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newWorkspace returns a workspace of packages in packages/<name without scope>, where deps maps
+// a package to the packages it depends on.
+func newWorkspace(root string, deps map[string][]string, packageNames ...string) *Workspace {
+	w := &Workspace{Root: root}
+	byName := make(map[string]*Package)
+	for _, name := range packageNames {
+		dir := name[strings.LastIndex(name, "/")+1:]
+		p := &Package{Name: name, Dir: filepath.Join(root, "packages", dir)}
+		byName[name] = p
+		w.Packages = append(w.Packages, p)
+	}
+	for _, p := range w.Packages {
+		for _, name := range deps[p.Name] {
+			dep := byName[name]
+			p.Dependencies = append(p.Dependencies, dep)
+			dep.Dependents = append(dep.Dependents, p)
+		}
+	}
+	return w
+}
+
+func TestFilter(t *testing.T) {
+	root := t.TempDir()
+	// app depends on ui and utils, ui depends on core, core depends on utils
+	w := newWorkspace(root, map[string][]string{
+		"@acme/app":  {"@acme/ui", "@acme/utils"},
+		"@acme/core": {"@acme/utils"},
+		"@acme/ui":   {"@acme/core"},
+	}, "@acme/app", "@acme/core", "@acme/ui", "@acme/utils", "docs")
+
+	tests := []struct {
+		name    string
+		filters []string
+		want    []string
+		wantErr string
+	}{
+		{name: "no filters", want: []string{"@acme/app", "@acme/core", "@acme/ui", "@acme/utils", "docs"}},
+		{name: "name", filters: []string{"@acme/ui"}, want: []string{"@acme/ui"}},
+		{name: "names", filters: []string{"docs", "@acme/core"}, want: []string{"@acme/core", "docs"}},
+		{name: "name glob", filters: []string{"@acme/*"}, want: []string{"@acme/app", "@acme/core", "@acme/ui", "@acme/utils"}},
+		{name: "glob across scope", filters: []string{"*s"}, want: []string{"@acme/utils", "docs"}},
+		{name: "directory", filters: []string{"./packages/ui"}, want: []string{"@acme/ui"}},
+		{name: "directory glob", filters: []string{"./packages/u*/"}, want: []string{"@acme/ui", "@acme/utils"}},
+		{name: "with dependencies", filters: []string{"@acme/ui..."}, want: []string{"@acme/core", "@acme/ui", "@acme/utils"}},
+		{name: "with dependents", filters: []string{"...@acme/core"}, want: []string{"@acme/app", "@acme/core", "@acme/ui"}},
+		{name: "with dependencies and dependents", filters: []string{"...@acme/core..."}, want: []string{"@acme/app", "@acme/core", "@acme/ui", "@acme/utils"}},
+		{name: "no match", filters: []string{"@other/*"}, wantErr: `no workspace package matches the filter "@other/*"`},
+		{name: "empty", filters: []string{"..."}, wantErr: `invalid filter "..."`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages, err := w.Filter(tt.filters)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(packages); !slices.Equal(got, tt.want) {
+				t.Errorf("Filter(%q) = %q, want %q", tt.filters, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"squish/internal/config"
+	"squish/internal/utils"
+	"strings"
+)
+
+// Package is a package of the workspace
+type Package struct {
+	Name string
+	Dir  string
+	// Dependencies are the workspace packages listed in its dependencies and peerDependencies
+	Dependencies []*Package
+	// Dependents are the workspace packages depending on it
+	Dependents []*Package
+	JSON       *config.PackageJSON
+}
+
+// Workspace is a monorepo whose packages are listed by the workspaces field of its package.json or
+// by pnpm-workspace.yaml
+type Workspace struct {
+	Root string
+	// Packages are sorted by name
+	Packages []*Package
+}
+
+// Discover reads the packages of the workspace rooted at root and the dependencies between them.
+func Discover(root string) (*Workspace, error) {
+	patterns, err := readPatterns(root)
+	if err != nil {
+		return nil, err
+	}
+
+	include, exclude := []string{}, []string{}
+	for _, pattern := range patterns {
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			exclude = append(exclude, cleanPattern(negated))
+		} else {
+			include = append(include, cleanPattern(pattern))
+		}
+	}
+
+	dirs, err := findPackageDirs(root, include, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Workspace{Root: root}
+	byName := make(map[string]*Package, len(dirs))
+	for _, dir := range dirs {
+		pkg, err := config.ReadPackageJSON(dir)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", filepath.Join(dir, "package.json"), err)
+		}
+		if pkg.Name == "" {
+			return nil, fmt.Errorf("workspace package %s has no name", utils.RelOrAbs(root, dir))
+		}
+		if other, ok := byName[pkg.Name]; ok {
+			return nil, fmt.Errorf("workspace packages %s and %s are both named %s", utils.RelOrAbs(root, other.Dir), utils.RelOrAbs(root, dir), pkg.Name)
+		}
+
+		p := &Package{Name: pkg.Name, Dir: dir, JSON: pkg}
+		byName[pkg.Name] = p
+		w.Packages = append(w.Packages, p)
+	}
+	sort.Slice(w.Packages, func(i, j int) bool {
+		return w.Packages[i].Name < w.Packages[j].Name
+	})
+
+	for _, p := range w.Packages {
+		names := []string{}
+		for _, deps := range []map[string]string{p.JSON.Dependencies, p.JSON.PeerDependencies} {
+			for name := range deps {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		seen := make(map[*Package]bool)
+		for _, name := range names {
+			dep, ok := byName[name]
+			if !ok || dep == p || seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			p.Dependencies = append(p.Dependencies, dep)
+			dep.Dependents = append(dep.Dependents, p)
+		}
+	}
+
+	if err := w.checkCycles(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// readPatterns returns the globs of the package directories, from the workspaces field of
+// package.json, either a list or an object with a packages list, or from pnpm-workspace.yaml.
+func readPatterns(root string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		var pkg struct {
+			Workspaces json.RawMessage `json:"workspaces"`
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil {
+			return nil, fmt.Errorf("error parsing package.json: %w", err)
+		}
+		if len(pkg.Workspaces) > 0 {
+			var patterns []string
+			if err := json.Unmarshal(pkg.Workspaces, &patterns); err == nil {
+				return patterns, nil
+			}
+			var object struct {
+				Packages []string `json:"packages"`
+			}
+			if err := json.Unmarshal(pkg.Workspaces, &object); err != nil {
+				return nil, fmt.Errorf("workspaces in package.json must be a list of globs or an object with a packages list")
+			}
+			return object.Packages, nil
+		}
+	}
+
+	data, err = os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml"))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no workspaces found: package.json has no workspaces field and there is no pnpm-workspace.yaml")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsePnpmWorkspace(string(data))
+}
+
+// parsePnpmWorkspace reads the packages list of pnpm-workspace.yaml, written either as a block
+// sequence or as a flow sequence.
+func parsePnpmWorkspace(data string) ([]string, error) {
+	patterns := []string{}
+	inPackages := false
+	for _, line := range strings.Split(data, "\n") {
+		line = stripYAMLComment(strings.TrimRight(line, "\r"))
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, "-") {
+			key, value, ok := strings.Cut(trimmed, ":")
+			inPackages = ok && strings.TrimSpace(key) == "packages"
+			if !inPackages {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+				for _, item := range strings.Split(value[1:len(value)-1], ",") {
+					if item = unquoteYAML(strings.TrimSpace(item)); item != "" {
+						patterns = append(patterns, item)
+					}
+				}
+				inPackages = false
+			} else if value != "" {
+				return nil, fmt.Errorf("packages in pnpm-workspace.yaml must be a list of globs")
+			}
+			continue
+		}
+
+		if inPackages {
+			item, ok := strings.CutPrefix(trimmed, "-")
+			if !ok {
+				return nil, fmt.Errorf("packages in pnpm-workspace.yaml must be a list of globs")
+			}
+			if item = unquoteYAML(strings.TrimSpace(item)); item != "" {
+				patterns = append(patterns, item)
+			}
+		}
+	}
+	return patterns, nil
+}
+
+// stripYAMLComment removes a comment from a line, outside of quotes.
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// cleanPattern normalizes a package directory glob to the slash separated form directories are
+// matched in, e.g. "./packages/*/" to "packages/*".
+func cleanPattern(pattern string) string {
+	pattern = strings.TrimSuffix(filepath.ToSlash(strings.TrimSpace(pattern)), "/")
+	for strings.HasPrefix(pattern, "./") {
+		pattern = pattern[2:]
+	}
+	return pattern
+}
+
+// findPackageDirs returns the directories below root with a package.json that match one of the
+// include globs and none of the exclude globs. node_modules and hidden directories are skipped.
+func findPackageDirs(root string, include, exclude []string) ([]string, error) {
+	dirs := []string{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && (d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if path == root {
+			return nil
+		}
+
+		rel := filepath.ToSlash(utils.RelOrAbs(root, path))
+		if !matchAny(include, rel) || matchAny(exclude, rel) {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, "package.json")); err == nil {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if config.MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCycles returns an error naming the packages of a dependency cycle, as the packages in it
+// cannot be built after each other.
+func (w *Workspace) checkCycles() error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*Package]int, len(w.Packages))
+	stack := []*Package{}
+
+	var visit func(p *Package) error
+	visit = func(p *Package) error {
+		switch state[p] {
+		case visited:
+			return nil
+		case visiting:
+			names := []string{}
+			for i := len(stack) - 1; i >= 0; i-- {
+				names = append([]string{stack[i].Name}, names...)
+				if stack[i] == p {
+					break
+				}
+			}
+			return fmt.Errorf("workspace packages depend on each other: %s -> %s", strings.Join(names, " -> "), p.Name)
+		}
+
+		state[p] = visiting
+		stack = append(stack, p)
+		for _, dep := range p.Dependencies {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[p] = visited
+		return nil
+	}
+
+	for _, p := range w.Packages {
+		if err := visit(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
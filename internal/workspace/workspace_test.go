@@ -0,0 +1,182 @@
+package workspace
+
+import (
+	"path/filepath"
+	"slices"
+	"squish/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func names(packages []*Package) []string {
+	result := []string{}
+	for _, p := range packages {
+		result = append(result, p.Name)
+	}
+	return result
+}
+
+func TestParsePnpmWorkspace(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "block sequence",
+			data: "packages:\n  - 'packages/*'\n  - \"apps/**\"\n  - '!**/test/**'\n",
+			want: []string{"packages/*", "apps/**", "!**/test/**"},
+		},
+		{
+			name: "unindented block sequence",
+			data: "packages:\n- packages/*\n- tools/cli\n",
+			want: []string{"packages/*", "tools/cli"},
+		},
+		{
+			name: "flow sequence",
+			data: "packages: ['packages/*', \"apps/*\"]\n",
+			want: []string{"packages/*", "apps/*"},
+		},
+		{
+			name: "comments, CRLF and other keys",
+			data: "# workspace\r\npackages:\r\n  # libraries\r\n  - packages/* # all of them\r\n  - 'docs#site'\r\ncatalog:\r\n  react: ^18.0.0\r\n",
+			want: []string{"packages/*", "docs#site"},
+		},
+		{
+			name: "no packages",
+			data: "catalog:\n  react: ^18.0.0\n",
+			want: []string{},
+		},
+		{
+			name:    "packages is not a list",
+			data:    "packages: packages/*\n",
+			wantErr: true,
+		},
+		{
+			name:    "packages is a map",
+			data:    "packages:\n  core: packages/core\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePnpmWorkspace(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePnpmWorkspace() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parsePnpmWorkspace() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		wantPackages []string
+		wantDeps     map[string][]string
+		wantErr      string
+	}{
+		{
+			name: "package.json workspaces",
+			files: map[string]string{
+				"package.json":                            `{"workspaces": ["./packages/*/", "!packages/private"]}`,
+				"packages/core/package.json":              `{"name": "@acme/core"}`,
+				"packages/ui/package.json":                `{"name": "@acme/ui", "dependencies": {"@acme/core": "*", "react": "^18"}, "peerDependencies": {"@acme/core": "*"}}`,
+				"packages/private/package.json":           `{"name": "private"}`,
+				"packages/ui/node_modules/x/package.json": `{"name": "x"}`,
+				"packages/docs/README.md":                 ``,
+			},
+			wantPackages: []string{"@acme/core", "@acme/ui"},
+			wantDeps:     map[string][]string{"@acme/ui": {"@acme/core"}},
+		},
+		{
+			name: "workspaces object",
+			files: map[string]string{
+				"package.json":              `{"workspaces": {"packages": ["apps/**"]}}`,
+				"apps/web/package.json":     `{"name": "web"}`,
+				"apps/web/sub/package.json": `{"name": "web-sub", "dependencies": {"web": "*"}}`,
+			},
+			wantPackages: []string{"web", "web-sub"},
+			wantDeps:     map[string][]string{"web-sub": {"web"}},
+		},
+		{
+			name: "pnpm-workspace.yaml",
+			files: map[string]string{
+				"package.json":        `{"name": "root"}`,
+				"pnpm-workspace.yaml": "packages:\n  - libs/*\n",
+				"libs/a/package.json": `{"name": "a"}`,
+			},
+			wantPackages: []string{"a"},
+		},
+		{
+			name:    "no workspaces",
+			files:   map[string]string{"package.json": `{"name": "root"}`},
+			wantErr: "no workspaces found",
+		},
+		{
+			name: "package without a name",
+			files: map[string]string{
+				"package.json":            `{"workspaces": ["packages/*"]}`,
+				"packages/a/package.json": `{}`,
+			},
+			wantErr: "workspace package packages/a has no name",
+		},
+		{
+			name: "duplicate names",
+			files: map[string]string{
+				"package.json":            `{"workspaces": ["packages/*"]}`,
+				"packages/a/package.json": `{"name": "a"}`,
+				"packages/b/package.json": `{"name": "a"}`,
+			},
+			wantErr: "workspace packages packages/a and packages/b are both named a",
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"package.json":            `{"workspaces": ["packages/*"]}`,
+				"packages/a/package.json": `{"name": "a", "dependencies": {"b": "*"}}`,
+				"packages/b/package.json": `{"name": "b", "dependencies": {"c": "*"}}`,
+				"packages/c/package.json": `{"name": "c", "peerDependencies": {"b": "*"}}`,
+			},
+			wantErr: "workspace packages depend on each other: b -> c -> b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			testutil.WriteFiles(t, root, tt.files)
+
+			w, err := Discover(root)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), filepath.FromSlash(tt.wantErr)) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := names(w.Packages); !slices.Equal(got, tt.wantPackages) {
+				t.Errorf("packages = %q, want %q", got, tt.wantPackages)
+			}
+			for _, p := range w.Packages {
+				if got, want := names(p.Dependencies), tt.wantDeps[p.Name]; !slices.Equal(got, want) {
+					t.Errorf("dependencies of %s = %q, want %q", p.Name, got, want)
+				}
+			}
+		})
+	}
+}
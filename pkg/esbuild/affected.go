@@ -0,0 +1,168 @@
+package esbuild
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"squish/internal/utils"
+	"strings"
+)
+
+// affectedBy reports whether the build of the outputs has to run for the changed paths, and why.
+// Every build is affected when changed is nil.
+func (b *Bundler) affectedBy(outputs string, changed map[string]bool) (string, bool) {
+	if changed == nil {
+		return "", true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if inputs, ok := b.transpiled[outputs]; ok {
+		return affectedByInputs(inputs, changed)
+	}
+
+	for _, c := range b.contexts {
+		if c.outputs != outputs {
+			continue
+		}
+		return affectedByInputs(c.inputs, changed)
+	}
+
+	return "not built before", true
+}
+
+// affectedEntries lists the entries of a split group whose inputs, as recorded by the previous
+// build of the group, include one of the changed paths. It lists every entry of the group when
+// the inputs of the entries are not known.
+func (b *Bundler) affectedEntries(group *splitGroup, changed map[string]bool) string {
+	outputs := group.outputs()
+	if changed == nil {
+		return outputs
+	}
+
+	b.mu.Lock()
+	metafile := ""
+	for _, c := range b.contexts {
+		if c.outputs == outputs {
+			metafile = c.metafile
+			break
+		}
+	}
+	b.mu.Unlock()
+
+	var meta rawMetafile
+	if metafile == "" || json.Unmarshal([]byte(metafile), &meta) != nil {
+		return outputs
+	}
+
+	affected := []string{}
+	for _, resolved := range group.entries {
+		outputPath := filepath.Join(b.config.DistDir, utils.GetDistRelativePath(resolved.entry.OutputPath, b.config.DistDir))
+		inputs := make(map[string]bool)
+		for input := range entryMetafile(meta, outputPath).Inputs {
+			if abs, err := filepath.Abs(input); err == nil {
+				inputs[abs] = true
+			}
+		}
+		if _, ok := affectedByInputs(inputs, changed); ok {
+			affected = append(affected, resolved.entry.OutputPath)
+		}
+	}
+	if len(affected) == 0 {
+		return outputs
+	}
+	return strings.Join(affected, ", ")
+}
+
+// affectedByInputs reports whether one of the changed paths is or contains an input of a build.
+func affectedByInputs(inputs map[string]bool, changed map[string]bool) (string, bool) {
+	if inputs == nil {
+		return "previous build failed", true
+	}
+	for _, path := range sortedPaths(changed) {
+		for input := range inputs {
+			if containsPath(path, input) {
+				return displayPath(path) + " changed", true
+			}
+		}
+	}
+	return "", false
+}
+
+// declarationsAffectedBy reports whether declarations have to be generated again for the changed
+// paths. tsc does not report the files it reads, so any change to a TypeScript file affects them.
+func (b *Bundler) declarationsAffectedBy(changed map[string]bool) (string, bool) {
+	if changed == nil {
+		return "", true
+	}
+	if b.declarationsFailed.Load() {
+		return "previous build failed", true
+	}
+
+	for _, path := range sortedPaths(changed) {
+		if isTypeScriptFile(path) {
+			return displayPath(path) + " changed", true
+		}
+		// A removed or renamed directory may have held TypeScript files
+		if filepath.Ext(path) == "" {
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				return displayPath(path) + " changed", true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// metafileInputs returns the absolute paths of the files in the inputs of an esbuild metafile.
+// Inputs of plugin namespaces, e.g. stubbed node builtins, are left out.
+func metafileInputs(metafile string) map[string]bool {
+	var meta struct {
+		Inputs map[string]json.RawMessage `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil
+	}
+
+	inputs := make(map[string]bool, len(meta.Inputs))
+	for input := range meta.Inputs {
+		if strings.Contains(input, ":") && !filepath.IsAbs(input) {
+			continue
+		}
+		if abs, err := filepath.Abs(input); err == nil {
+			inputs[abs] = true
+		}
+	}
+	return inputs
+}
+
+func isTypeScriptFile(path string) bool {
+	_, ok := declarationExtensions[filepath.Ext(path)]
+	return ok
+}
+
+// containsPath reports whether path is the input itself or a directory containing it.
+func containsPath(path, input string) bool {
+	return input == path || strings.HasPrefix(input, path+string(filepath.Separator))
+}
+
+// displayPath returns a path relative to the working directory for log messages.
+func displayPath(path string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			return rel
+		}
+	}
+	return path
+}
+
+func sortedPaths(paths map[string]bool) []string {
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
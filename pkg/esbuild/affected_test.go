@@ -0,0 +1,74 @@
+package esbuild
+
+import (
+	"os"
+	"path/filepath"
+	"squish/internal/config"
+	"squish/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestRebuildLogsAffectedEntries(t *testing.T) {
+	useTempUserCache(t)
+	dir := t.TempDir()
+	testutil.WriteFiles(t, dir, map[string]string{
+		"package.json":  `{"name": "pkg", "type": "module", "exports": {".": "./dist/index.js", "./b": "./dist/b.js", "./c": "./dist/c.js"}}`,
+		"src/index.js":  `import { shared } from "./shared.js"; export const a = shared + 1;`,
+		"src/b.js":      `import { shared } from "./shared.js"; import { own } from "./own.js"; export const b = shared + own;`,
+		"src/c.js":      `export const c = 3;`,
+		"src/own.js":    `export const own = 2;`,
+		"src/shared.js": `export const shared = 1;`,
+	})
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	pkg, err := config.ReadPackageJSON(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBundler(&BundlerConfig{PackageDir: dir, SrcDir: "src", DistDir: "dist"}, pkg)
+	defer b.Dispose()
+
+	var bundleErr error
+	captureStdout(t, func() { bundleErr = b.Bundle() })
+	if bundleErr != nil {
+		t.Fatal(bundleErr)
+	}
+
+	tests := []struct {
+		changed string
+		// rebuilt are the entries logged as rebuilt
+		rebuilt string
+	}{
+		{changed: "src/own.js", rebuilt: "./dist/b.js"},
+		{changed: "src/shared.js", rebuilt: "./dist/index.js, ./dist/b.js"},
+		{changed: "src/c.js", rebuilt: "./dist/c.js"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.changed, func(t *testing.T) {
+			var rebuildErr error
+			out := captureStdout(t, func() { _, rebuildErr = b.Rebuild([]string{filepath.Join(dir, tt.changed)}) })
+			if rebuildErr != nil {
+				t.Fatal(rebuildErr)
+			}
+			rebuilding := []string{}
+			for _, line := range strings.Split(out, "\n") {
+				if _, message, ok := strings.Cut(line, "] Rebuilding "); ok {
+					rebuilding = append(rebuilding, "Rebuilding "+message)
+				}
+			}
+			want := "Rebuilding " + tt.rebuilt + ": " + filepath.FromSlash(tt.changed) + " changed"
+			if len(rebuilding) != 1 || rebuilding[0] != want {
+				t.Errorf("rebuild log = %q, want %q", rebuilding, want)
+			}
+		})
+	}
+}
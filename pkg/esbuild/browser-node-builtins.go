@@ -0,0 +1,114 @@
+package esbuild
+
+import (
+	"fmt"
+	"github.com/evanw/esbuild/pkg/api"
+	"slices"
+	"strings"
+)
+
+const (
+	BrowserBuiltinsError = "error"
+	BrowserBuiltinsStub  = "stub"
+)
+
+// browserBuiltinsPluginData marks the resolutions the browser builtins plugin starts itself, so
+// it does not handle them again
+const browserBuiltinsPluginData = "squish-browser-builtins"
+
+// BrowserNodeBuiltinsPlugin handles Node builtin imports in browser builds, which have no Node
+// runtime to provide them. Depending on mode they either fail the build or resolve to an empty
+// module. "node:" imports always refer to the builtin, while bare names like "events" are only
+// handled when they do not resolve to an installed package such as a polyfill. Builtins replaced
+// through the package's browser field are resolved through it, with or without the "node:" prefix.
+func BrowserNodeBuiltinsPlugin(mode string, browserModules []string) Plugin {
+	const namespace = "node-builtin-stub"
+
+	return NewPluginBuilder("browser-node-builtins").
+		Setup(func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: ".*"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				if args.PluginData == browserBuiltinsPluginData || !isNodeBuiltin(args.Path) {
+					return api.OnResolveResult{}, nil
+				}
+
+				name, prefixed := strings.CutPrefix(args.Path, "node:")
+				if slices.Contains(browserModules, name) {
+					if !prefixed {
+						return api.OnResolveResult{}, nil
+					}
+					// The browser field maps the bare name, which esbuild does not apply to "node:" imports
+					resolved := resolveBuiltin(build, name, args)
+					if len(resolved.Errors) == 0 && resolved.Namespace == "" {
+						// Mapped to false, which esbuild resolves to a path without a namespace
+						return api.OnResolveResult{Path: args.Path, Namespace: namespace}, nil
+					}
+					return resolved, nil
+				}
+				if !prefixed {
+					if resolved := resolveBuiltin(build, name, args); len(resolved.Errors) == 0 {
+						return resolved, nil
+					}
+				}
+
+				if mode == BrowserBuiltinsStub {
+					return api.OnResolveResult{
+						Path:      args.Path,
+						Namespace: namespace,
+					}, nil
+				}
+
+				return api.OnResolveResult{
+					Errors: []api.Message{{
+						Text: fmt.Sprintf("Node builtin %q cannot be used in a browser build", args.Path),
+						Notes: []api.Note{{
+							Text: fmt.Sprintf("Install a browser polyfill, map it in the package.json browser field, e.g. \"browser\": { %q: false }, or use --browser-builtins=stub", name),
+						}},
+					}},
+				}, nil
+			})
+
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: namespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				contents := "module.exports = {};"
+				return api.OnLoadResult{
+					Contents: &contents,
+					Loader:   api.LoaderJS,
+				}, nil
+			})
+		}).
+		Build()
+}
+
+// resolveBuiltin resolves the bare name of a builtin the way esbuild would without the plugin,
+// which finds installed polyfills and applies the browser field.
+func resolveBuiltin(build api.PluginBuild, name string, args api.OnResolveArgs) api.OnResolveResult {
+	resolved := build.Resolve(name, api.ResolveOptions{
+		Importer:   args.Importer,
+		ResolveDir: args.ResolveDir,
+		Kind:       args.Kind,
+		PluginData: browserBuiltinsPluginData,
+		With:       args.With,
+	})
+	if len(resolved.Errors) > 0 {
+		return api.OnResolveResult{Errors: resolved.Errors}
+	}
+	sideEffects := api.SideEffectsTrue
+	if !resolved.SideEffects {
+		sideEffects = api.SideEffectsFalse
+	}
+	return api.OnResolveResult{
+		Path:        resolved.Path,
+		External:    resolved.External,
+		SideEffects: sideEffects,
+		Namespace:   resolved.Namespace,
+		Suffix:      resolved.Suffix,
+		PluginData:  resolved.PluginData,
+	}
+}
+
+func isNodeBuiltin(path string) bool {
+	if strings.HasPrefix(path, "node:") {
+		return true
+	}
+	name, _, _ := strings.Cut(path, "/")
+	return slices.Contains(nodeBuiltins, name)
+}
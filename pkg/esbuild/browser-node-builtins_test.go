@@ -0,0 +1,115 @@
+package esbuild
+
+import (
+	"github.com/evanw/esbuild/pkg/api"
+	"path/filepath"
+	"squish/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestBrowserNodeBuiltinsPlugin(t *testing.T) {
+	polyfill := map[string]string{
+		"node_modules/events/package.json": `{"name": "events", "main": "./events.js"}`,
+		"node_modules/events/events.js":    `module.exports = { polyfill: "events" };`,
+	}
+	tests := []struct {
+		name      string
+		source    string
+		files     map[string]string
+		browser   []string
+		mode      string
+		wantError string
+		want      string
+	}{
+		{
+			name:   "bare name resolving to an installed polyfill",
+			source: `import events from "events"; console.log(events);`,
+			files:  polyfill,
+			mode:   BrowserBuiltinsError,
+			want:   `polyfill: "events"`,
+		},
+		{
+			name:      "bare name without a polyfill",
+			source:    `import fs from "fs"; console.log(fs);`,
+			mode:      BrowserBuiltinsError,
+			wantError: `Node builtin "fs" cannot be used in a browser build`,
+		},
+		{
+			name:   "bare name without a polyfill stubbed",
+			source: `import fs from "fs"; console.log(fs);`,
+			mode:   BrowserBuiltinsStub,
+			want:   `module.exports = {};`,
+		},
+		{
+			name:      "node: prefix with an installed polyfill",
+			source:    `import events from "node:events"; console.log(events);`,
+			files:     polyfill,
+			mode:      BrowserBuiltinsError,
+			wantError: `Node builtin "node:events" cannot be used in a browser build`,
+		},
+		{
+			name:   "node: prefix stubbed",
+			source: `import events from "node:events"; console.log(events);`,
+			files:  polyfill,
+			mode:   BrowserBuiltinsStub,
+			want:   `module.exports = {};`,
+		},
+		{
+			name:    "bare name mapped in the browser field",
+			source:  `import fs from "fs"; console.log(fs);`,
+			files:   map[string]string{"package.json": `{"browser": {"fs": false}}`},
+			browser: []string{"fs"},
+			mode:    BrowserBuiltinsError,
+		},
+		{
+			name:    "node: prefix mapped in the browser field",
+			source:  `import fs from "node:fs"; console.log(fs);`,
+			files:   map[string]string{"package.json": `{"browser": {"fs": false}}`},
+			browser: []string{"fs"},
+			mode:    BrowserBuiltinsError,
+		},
+		{
+			name:    "node: prefix mapped to a polyfill in the browser field",
+			source:  `import events from "node:events"; console.log(events);`,
+			files:   map[string]string{"package.json": `{"browser": {"events": "./shim.js"}}`, "shim.js": `module.exports = { shim: true };`},
+			browser: []string{"events"},
+			mode:    BrowserBuiltinsError,
+			want:    `shim: true`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{"index.js": tt.source}
+			for name, contents := range tt.files {
+				files[name] = contents
+			}
+			testutil.WriteFiles(t, dir, files)
+
+			result := api.Build(api.BuildOptions{
+				EntryPoints:   []string{filepath.Join(dir, "index.js")},
+				AbsWorkingDir: dir,
+				Bundle:        true,
+				Platform:      api.PlatformBrowser,
+				Format:        api.FormatESModule,
+				LogLevel:      api.LogLevelSilent,
+				Plugins:       []api.Plugin{createEsbuildPlugin(BrowserNodeBuiltinsPlugin(tt.mode, tt.browser))},
+			})
+
+			if tt.wantError != "" {
+				if len(result.Errors) == 0 || result.Errors[0].Text != tt.wantError {
+					t.Fatalf("errors = %v, want %q", result.Errors, tt.wantError)
+				}
+				return
+			}
+			if len(result.Errors) > 0 {
+				t.Fatalf("unexpected errors %v", result.Errors)
+			}
+			if code := string(result.OutputFiles[0].Contents); !strings.Contains(code, tt.want) {
+				t.Errorf("output does not contain %q:\n%s", tt.want, code)
+			}
+		})
+	}
+}
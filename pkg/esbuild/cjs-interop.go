@@ -0,0 +1,56 @@
+package esbuild
+
+import (
+	"github.com/evanw/esbuild/pkg/api"
+	"squish/internal/config"
+	"strings"
+)
+
+// cjsInteropFooter makes the default export of a CommonJS output its module.exports, so require
+// consumers do not need .default. Named exports are merged onto an object or function default
+// export when it has no properties of the same names, otherwise module.exports stays as it is.
+// The default export stays reachable as .default for consumers typed against the ESM shape.
+const cjsInteropFooter = `(function(e) {
+  if (!e || !e.__esModule || !("default" in e)) return;
+  var d = e.default, names = Object.keys(e).filter(function(k) { return k !== "default"; });
+  var isObject = d !== null && (typeof d === "object" || typeof d === "function");
+  if (!isObject) {
+    if (names.length === 0) module.exports = d;
+    return;
+  }
+  if (!Object.isExtensible(d) || names.some(function(k) { return k in d; })) return;
+  names.forEach(function(k) {
+    Object.defineProperty(d, k, { enumerable: true, get: function() { return e[k]; } });
+  });
+  if (!("default" in d)) Object.defineProperty(d, "default", { value: d });
+  module.exports = d;
+})(module.exports);`
+
+// getCjsInteropFooter returns the interop footer, minified for minified builds.
+func getCjsInteropFooter(minify bool) string {
+	if !minify {
+		return cjsInteropFooter
+	}
+	result := api.Transform(cjsInteropFooter, api.TransformOptions{
+		MinifyWhitespace:  true,
+		MinifyIdentifiers: true,
+		MinifySyntax:      true,
+	})
+	if len(result.Errors) > 0 {
+		return cjsInteropFooter
+	}
+	return strings.TrimSpace(string(result.Code))
+}
+
+// describesCommonJS reports whether TypeScript reads a declaration file as describing CommonJS,
+// through its .d.cts extension or the package type.
+func (b *Bundler) describesCommonJS(outputPath string) bool {
+	switch {
+	case strings.HasSuffix(outputPath, ".d.cts"):
+		return true
+	case strings.HasSuffix(outputPath, ".d.mts"):
+		return false
+	default:
+		return b.pkg.Type != config.PackageTypeModule
+	}
+}
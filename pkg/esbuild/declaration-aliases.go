@@ -0,0 +1,102 @@
+package esbuild
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"squish/internal/utils"
+	"strings"
+)
+
+// declarationSpecifierPattern matches the specifiers of imports, exports, import types and
+// import-equals declarations
+var declarationSpecifierPattern = regexp.MustCompile(`(from\s*|import\s*\(\s*|import\s+|require\s*\(\s*)(["'])([^"'\n]+)["']`)
+
+// aliasSourceExtensions map the extension of a file a paths alias points at to the extension of
+// the relative specifier replacing the alias, which the declaration rollup resolves
+var aliasSourceExtensions = map[string]string{
+	".ts": "", ".tsx": "", ".d.ts": "", ".js": "", ".jsx": "",
+	".mts": ".mjs", ".d.mts": ".mjs", ".mjs": ".mjs",
+	".cts": ".cjs", ".d.cts": ".cjs", ".cjs": ".cjs",
+}
+
+// rewriteDeclarationAliases replaces imports resolved through the paths and baseUrl of the
+// tsconfig in the declarations tsc emitted, which tsc keeps as they are, with relative imports of
+// the emitted declarations. Aliases of files outside the emitted tree are kept.
+func (b *Bundler) rewriteDeclarationAliases(tmpDir string, entries []declarationEntry) error {
+	tsconfigPath := b.getTsconfigPath()
+	if tsconfigPath == "" {
+		return nil
+	}
+	tsconfig, err := utils.ReadTsconfig(tsconfigPath)
+	if err != nil {
+		return err
+	}
+	if tsconfig.Paths == nil && tsconfig.BaseURL == "" {
+		return nil
+	}
+
+	root, err := b.emittedDeclarationRoot(tmpDir, entries)
+	if err != nil || root == "" {
+		return err
+	}
+
+	// tmpDir mirrors the directory as many levels above the source directory as root is below tmpDir
+	sourceRoot, err := filepath.Abs(b.config.SrcDir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(tmpDir, root)
+	if err != nil {
+		return err
+	}
+	if rel != "." {
+		for range strings.Split(rel, string(filepath.Separator)) {
+			sourceRoot = filepath.Dir(sourceRoot)
+		}
+	}
+
+	return filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isDeclarationFile(path) {
+			return nil
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rewritten := declarationSpecifierPattern.ReplaceAllStringFunc(string(contents), func(match string) string {
+			parts := declarationSpecifierPattern.FindStringSubmatch(match)
+			target, ok := tsconfig.ResolveAlias(parts[3])
+			if !ok || !containsPath(sourceRoot, target) || strings.Contains(target, "node_modules") {
+				return match
+			}
+			emitted := filepath.Join(tmpDir, utils.RelOrAbs(sourceRoot, target))
+			return parts[1] + parts[2] + relativeSpecifier(filepath.Dir(path), emitted) + parts[2]
+		})
+		if rewritten == string(contents) {
+			return nil
+		}
+		return os.WriteFile(path, []byte(rewritten), info.Mode())
+	})
+}
+
+// relativeSpecifier returns the relative import of a file from a directory, with the extension
+// of the file it describes in place of its source extension.
+func relativeSpecifier(dir, file string) string {
+	for _, ext := range []string{".d.ts", ".d.mts", ".d.cts", ".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"} {
+		if strings.HasSuffix(file, ext) {
+			file = strings.TrimSuffix(file, ext) + aliasSourceExtensions[ext]
+			break
+		}
+	}
+
+	specifier := filepath.ToSlash(utils.RelOrAbs(dir, file))
+	if !strings.HasPrefix(specifier, ".") {
+		specifier = "./" + specifier
+	}
+	return specifier
+}
@@ -0,0 +1,987 @@
+package esbuild
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The declaration rollup follows the import graph of an entry declaration file and hoists every
+// local module into a single file. Top-level names are renamed where modules collide, local
+// imports are replaced by the names they resolve to and imports of packages stay external.
+
+var referenceDirectivePattern = regexp.MustCompile(`^///\s*<reference\s`)
+
+type dtsModule struct {
+	path       string
+	statements []*dtsStatement
+	deps       map[string]*dtsModule
+
+	declared    map[string]bool
+	imports     map[string]dtsBinding
+	exports     map[string]dtsBinding
+	exportOrder []string
+	stars       []string
+
+	renames map[string]string
+}
+
+// dtsBinding points at a name exported by a module. An empty module means the name is a
+// declaration of the module itself.
+type dtsBinding struct {
+	module string
+	name   string
+}
+
+type dtsExternalImport struct {
+	module string
+	name   string
+	local  string
+}
+
+type declarationRollup struct {
+	roots      []string
+	isExternal func(specifier string) bool
+
+	modules []*dtsModule
+	byPath  map[string]*dtsModule
+
+	used            map[string]bool
+	externalNames   map[dtsExternalImport]string
+	externalImports []dtsExternalImport
+	sideEffects     []string
+	namespaces      map[*dtsModule]string
+	namespaceOrder  []*dtsModule
+	references      []string
+	warnings        []string
+	// undeclared are the imported packages consumers may not have installed
+	undeclared []string
+	// assignDefault describes a default export as `export =`, for CommonJS outputs whose
+	// module.exports is their default export
+	assignDefault bool
+}
+
+// rollupDeclarations bundles the declaration file at entryPath into a single self-contained file.
+// roots lists directories that mirror each other's layout, such as the tsc output directory and
+// the source directory containing hand-written declarations. With assignDefault, an entry whose
+// only export is its default export is declared with `export =`.
+func rollupDeclarations(entryPath string, roots []string, isExternal func(string) bool, assignDefault bool) (string, []string, error) {
+	r := &declarationRollup{
+		roots:         roots,
+		isExternal:    isExternal,
+		assignDefault: assignDefault,
+		byPath:        make(map[string]*dtsModule),
+		used:          make(map[string]bool),
+		externalNames: make(map[dtsExternalImport]string),
+		namespaces:    make(map[*dtsModule]string),
+	}
+
+	entry, err := r.load(entryPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	r.reserveFreeNames()
+	r.allocateDeclarations(entry)
+	if err := r.link(); err != nil {
+		return "", nil, err
+	}
+
+	output, err := r.emit(entry)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(r.undeclared) > 0 {
+		slices.Sort(r.undeclared)
+		quoted := make([]string, 0, len(r.undeclared))
+		for _, module := range r.undeclared {
+			quoted = append(quoted, strconv.Quote(module))
+		}
+		return "", nil, fmt.Errorf("declarations import packages that are not listed in dependencies or peerDependencies, so their types do not resolve for consumers: %s", strings.Join(quoted, ", "))
+	}
+	return output, r.warnings, nil
+}
+
+func (r *declarationRollup) load(path string) (*dtsModule, error) {
+	path = filepath.Clean(path)
+	if m, ok := r.byPath[path]; ok {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := tokenizeDeclarations(string(data))
+	tokens = r.extractReferences(tokens)
+
+	m := &dtsModule{
+		path:       path,
+		statements: splitDeclarationStatements(tokens),
+		deps:       make(map[string]*dtsModule),
+		declared:   make(map[string]bool),
+		imports:    make(map[string]dtsBinding),
+		exports:    make(map[string]dtsBinding),
+		renames:    make(map[string]string),
+	}
+	r.byPath[path] = m
+
+	for _, specifier := range localSpecifiers(m.statements) {
+		if _, ok := m.deps[specifier]; ok {
+			continue
+		}
+		depPath, err := r.resolve(path, specifier)
+		if err != nil {
+			return nil, err
+		}
+		dep, err := r.load(depPath)
+		if err != nil {
+			return nil, err
+		}
+		m.deps[specifier] = dep
+	}
+
+	r.collectBindings(m)
+	// Modules are stored after their dependencies so their declarations are emitted first
+	r.modules = append(r.modules, m)
+
+	return m, nil
+}
+
+// extractReferences removes triple-slash reference directives, which are only valid at the top of
+// the combined file.
+func (r *declarationRollup) extractReferences(tokens []dtsToken) []dtsToken {
+	filtered := tokens[:0:0]
+	for _, t := range tokens {
+		if t.kind == dtsComment && referenceDirectivePattern.MatchString(t.text) {
+			if !containsString(r.references, t.text) {
+				r.references = append(r.references, t.text)
+			}
+			continue
+		}
+		filtered = append(filtered, t)
+	}
+	return filtered
+}
+
+func localSpecifiers(statements []*dtsStatement) []string {
+	specifiers := []string{}
+	for _, s := range statements {
+		if (s.kind == dtsImport || s.kind == dtsExportFrom) && isLocalSpecifier(s.module) {
+			specifiers = append(specifiers, s.module)
+		}
+		forEachImportType(s.tokens, func(specifier string, _, _ int) {
+			if isLocalSpecifier(specifier) {
+				specifiers = append(specifiers, specifier)
+			}
+		})
+	}
+	return specifiers
+}
+
+// forEachImportType calls fn for every `import("specifier")` type in tokens with the indexes of
+// the `import` keyword and the closing parenthesis.
+func forEachImportType(tokens []dtsToken, fn func(specifier string, start, end int)) {
+	for i, t := range tokens {
+		if !t.is("import") {
+			continue
+		}
+		open := nextSignificant(tokens, i)
+		if open < 0 || !tokens[open].is("(") {
+			continue
+		}
+		str := nextSignificant(tokens, open)
+		if str < 0 || tokens[str].kind != dtsString {
+			continue
+		}
+		closing := nextSignificant(tokens, str)
+		if closing < 0 || !tokens[closing].is(")") {
+			continue
+		}
+		fn(unquoteDtsString(tokens[str].text), i, closing)
+	}
+}
+
+func isLocalSpecifier(specifier string) bool {
+	return strings.HasPrefix(specifier, ".") || strings.HasPrefix(specifier, "/")
+}
+
+// resolve finds the declaration file for a relative import, looking in every root so emitted and
+// hand-written declarations can import each other.
+func (r *declarationRollup) resolve(from, specifier string) (string, error) {
+	base := filepath.Join(filepath.Dir(from), specifier)
+	if strings.HasPrefix(specifier, "/") {
+		base = specifier
+	}
+
+	bases := []string{base}
+	for _, root := range r.roots {
+		rel, err := filepath.Rel(root, base)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		for _, other := range r.roots {
+			if other != root {
+				bases = append(bases, filepath.Join(other, rel))
+			}
+		}
+		break
+	}
+
+	for _, b := range bases {
+		for _, candidate := range declarationCandidates(b) {
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("could not resolve declaration import %q from %s", specifier, from)
+}
+
+func declarationCandidates(base string) []string {
+	for _, ext := range []string{".d.ts", ".d.mts", ".d.cts"} {
+		if strings.HasSuffix(base, ext) {
+			return []string{base}
+		}
+	}
+
+	replacements := map[string]string{
+		".js": ".d.ts", ".jsx": ".d.ts", ".ts": ".d.ts", ".tsx": ".d.ts",
+		".mjs": ".d.mts", ".mts": ".d.mts", ".cjs": ".d.cts", ".cts": ".d.cts",
+	}
+	if replacement, ok := replacements[filepath.Ext(base)]; ok {
+		return []string{strings.TrimSuffix(base, filepath.Ext(base)) + replacement}
+	}
+
+	return []string{
+		base + ".d.ts",
+		base + ".d.mts",
+		base + ".d.cts",
+		filepath.Join(base, "index.d.ts"),
+		filepath.Join(base, "index.d.mts"),
+		filepath.Join(base, "index.d.cts"),
+	}
+}
+
+func (r *declarationRollup) collectBindings(m *dtsModule) {
+	addExport := func(name string, binding dtsBinding) {
+		if _, ok := m.exports[name]; !ok {
+			m.exportOrder = append(m.exportOrder, name)
+		}
+		m.exports[name] = binding
+	}
+
+	for _, s := range m.statements {
+		switch s.kind {
+		case dtsImport:
+			if s.module == "" {
+				continue
+			}
+			if s.defaultImport != "" {
+				m.imports[s.defaultImport] = dtsBinding{module: s.module, name: "default"}
+			}
+			if s.namespace != "" {
+				m.imports[s.namespace] = dtsBinding{module: s.module, name: "*"}
+			}
+			if s.require != "" {
+				m.imports[s.require] = dtsBinding{module: s.module, name: "="}
+			}
+			for _, spec := range s.specifiers {
+				m.imports[spec.alias] = dtsBinding{module: s.module, name: spec.name}
+			}
+			if s.defaultImport == "" && s.namespace == "" && s.require == "" && s.specifiers == nil && !isLocalSpecifier(s.module) {
+				if !containsString(r.sideEffects, s.module) {
+					r.sideEffects = append(r.sideEffects, s.module)
+				}
+			}
+
+		case dtsExportFrom:
+			switch {
+			case s.star && s.namespace != "":
+				addExport(s.namespace, dtsBinding{module: s.module, name: "*"})
+			case s.star:
+				m.stars = append(m.stars, s.module)
+			default:
+				for _, spec := range s.specifiers {
+					addExport(spec.alias, dtsBinding{module: s.module, name: spec.name})
+				}
+			}
+
+		case dtsExportList:
+			for _, spec := range s.specifiers {
+				addExport(spec.alias, dtsBinding{name: spec.name})
+			}
+
+		case dtsExportDefaultName:
+			addExport("default", dtsBinding{name: s.name})
+
+		case dtsDeclaration:
+			for _, name := range s.names {
+				m.declared[name] = true
+				if s.exported && !s.isDefault {
+					addExport(name, dtsBinding{name: name})
+				}
+			}
+			if s.exported && s.isDefault {
+				name := "_default"
+				if len(s.names) > 0 {
+					name = s.names[0]
+				} else {
+					m.declared[name] = true
+				}
+				addExport("default", dtsBinding{name: name})
+			}
+		}
+	}
+}
+
+// reserveFreeNames marks every name a module references without declaring or importing it, such
+// as globals, so that no hoisted declaration shadows it.
+func (r *declarationRollup) reserveFreeNames() {
+	for _, m := range r.modules {
+		for _, s := range m.statements {
+			if s.kind != dtsDeclaration && s.kind != dtsAmbient {
+				continue
+			}
+			scopes := newDtsScopes(s.tokens)
+			for i, t := range s.tokens {
+				if t.kind != dtsIdent || m.declared[t.text] {
+					continue
+				}
+				if _, ok := m.imports[t.text]; ok {
+					continue
+				}
+				if isReferencePosition(s.tokens, scopes, s.keyword, i) {
+					r.used[t.text] = true
+				}
+			}
+		}
+	}
+}
+
+// allocateDeclarations assigns a unique top-level name to every declaration, starting with the
+// entry so its names are the least likely to need renaming.
+func (r *declarationRollup) allocateDeclarations(entry *dtsModule) {
+	ordered := append([]*dtsModule{entry}, r.modules...)
+	for _, m := range ordered {
+		if len(m.renames) > 0 {
+			continue
+		}
+		names := make([]string, 0, len(m.declared))
+		for name := range m.declared {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			m.renames[name] = r.uniqueName(name)
+		}
+	}
+}
+
+func (r *declarationRollup) uniqueName(name string) string {
+	candidate := name
+	for i := 1; r.used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s$%d", name, i)
+	}
+	r.used[candidate] = true
+	return candidate
+}
+
+// link resolves every imported binding to the top-level name it refers to.
+func (r *declarationRollup) link() error {
+	for _, m := range r.modules {
+		locals := make([]string, 0, len(m.imports))
+		for local := range m.imports {
+			locals = append(locals, local)
+		}
+		sort.Strings(locals)
+
+		for _, local := range locals {
+			name, err := r.resolveBinding(m, m.imports[local], local, map[string]bool{})
+			if err != nil {
+				return err
+			}
+			m.renames[local] = name
+		}
+	}
+	return nil
+}
+
+func (r *declarationRollup) resolveBinding(m *dtsModule, binding dtsBinding, preferred string, seen map[string]bool) (string, error) {
+	if binding.module == "" {
+		if name, ok := m.renames[binding.name]; ok && m.declared[binding.name] {
+			return name, nil
+		}
+		if imported, ok := m.imports[binding.name]; ok {
+			return r.resolveBinding(m, imported, binding.name, seen)
+		}
+		// Not declared here, so it refers to a global
+		return binding.name, nil
+	}
+
+	if !isLocalSpecifier(binding.module) {
+		return r.externalName(binding.module, binding.name, preferred), nil
+	}
+
+	target := m.deps[binding.module]
+	if binding.name == "*" || binding.name == "=" {
+		return r.namespaceName(target, preferred), nil
+	}
+
+	name, ok, err := r.resolveExport(target, binding.name, seen)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%s does not export %q (imported by %s)", target.path, binding.name, m.path)
+	}
+	return name, nil
+}
+
+func (r *declarationRollup) resolveExport(m *dtsModule, name string, seen map[string]bool) (string, bool, error) {
+	key := m.path + "\x00" + name
+	if seen[key] {
+		return "", false, nil
+	}
+	seen[key] = true
+
+	if binding, ok := m.exports[name]; ok {
+		resolved, err := r.resolveBinding(m, binding, name, seen)
+		return resolved, err == nil, err
+	}
+
+	if name == "default" {
+		return "", false, nil
+	}
+
+	for _, star := range m.stars {
+		if !isLocalSpecifier(star) {
+			continue
+		}
+		if resolved, ok, err := r.resolveExport(m.deps[star], name, seen); ok || err != nil {
+			return resolved, ok, err
+		}
+	}
+
+	return "", false, nil
+}
+
+// exportNames lists the names a module exports, including those re-exported through local stars.
+func (r *declarationRollup) exportNames(m *dtsModule, seen map[*dtsModule]bool) []string {
+	if seen[m] {
+		return nil
+	}
+	seen[m] = true
+
+	names := append([]string{}, m.exportOrder...)
+	for _, star := range m.stars {
+		if !isLocalSpecifier(star) {
+			continue
+		}
+		for _, name := range r.exportNames(m.deps[star], seen) {
+			if name != "default" && !containsString(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// externalStars lists the packages re-exported with `export *` from the module or its local stars.
+func (r *declarationRollup) externalStars(m *dtsModule, seen map[*dtsModule]bool) []string {
+	if seen[m] {
+		return nil
+	}
+	seen[m] = true
+
+	stars := []string{}
+	for _, star := range m.stars {
+		if isLocalSpecifier(star) {
+			stars = append(stars, r.externalStars(m.deps[star], seen)...)
+		} else {
+			r.checkExternal(star)
+			stars = append(stars, star)
+		}
+	}
+	return stars
+}
+
+func (r *declarationRollup) externalName(module, name, preferred string) string {
+	key := dtsExternalImport{module: module, name: name}
+	if local, ok := r.externalNames[key]; ok {
+		return local
+	}
+
+	if preferred == "" {
+		preferred = name
+	}
+	if preferred == "*" || preferred == "=" || preferred == "default" {
+		preferred = identifierFromPath(module)
+	}
+
+	r.checkExternal(module)
+	local := r.uniqueName(preferred)
+	r.externalNames[key] = local
+	r.externalImports = append(r.externalImports, dtsExternalImport{module: module, name: name, local: local})
+	return local
+}
+
+func (r *declarationRollup) namespaceName(m *dtsModule, preferred string) string {
+	if name, ok := r.namespaces[m]; ok {
+		return name
+	}
+	if preferred == "" || preferred == "*" || preferred == "=" {
+		preferred = identifierFromPath(m.path)
+	}
+	name := r.uniqueName(preferred)
+	r.namespaces[m] = name
+	r.namespaceOrder = append(r.namespaceOrder, m)
+	return name
+}
+
+// checkExternal records an imported package that is not installed alongside the package, which
+// fails the rollup as the declarations would not resolve for consumers.
+func (r *declarationRollup) checkExternal(module string) {
+	if slices.Contains(r.undeclared, module) || r.isExternal(module) {
+		return
+	}
+	r.undeclared = append(r.undeclared, module)
+}
+
+var nonIdentifierPattern = regexp.MustCompile(`[^A-Za-z0-9_$]+`)
+
+func identifierFromPath(path string) string {
+	base := filepath.Base(path)
+	for _, ext := range []string{".d.ts", ".d.mts", ".d.cts"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	name := nonIdentifierPattern.ReplaceAllString(base, "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+func (r *declarationRollup) emit(entry *dtsModule) (string, error) {
+	var body []string
+	exportedInPlace := make(map[string]bool)
+	assignDefault, err := r.defaultAssignment(entry)
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range r.modules {
+		for _, s := range m.statements {
+			if s.kind != dtsDeclaration && s.kind != dtsAmbient {
+				continue
+			}
+
+			keepExport := m == entry && s.kind == dtsDeclaration && s.exported && r.keepsNames(m, s) && !(s.isDefault && assignDefault != "")
+			tokens, err := r.rewriteStatement(m, s, keepExport)
+			if err != nil {
+				return "", err
+			}
+			if keepExport {
+				if s.isDefault {
+					exportedInPlace["default"] = true
+				} else {
+					for _, name := range s.names {
+						exportedInPlace[name] = true
+					}
+				}
+			}
+			body = append(body, strings.TrimSpace(joinTokens(tokens)))
+		}
+	}
+
+	var footer []string
+	var assignment, asNamespace string
+	for _, s := range entry.statements {
+		switch s.kind {
+		case dtsExportAssignment:
+			name, err := r.resolveBinding(entry, dtsBinding{name: s.name}, s.name, map[string]bool{})
+			if err != nil {
+				return "", err
+			}
+			assignment = fmt.Sprintf("export = %s;", name)
+		case dtsExportAsNamespace:
+			asNamespace = fmt.Sprintf("export as namespace %s;", s.name)
+		}
+	}
+
+	if assignment == "" && assignDefault != "" {
+		assignment = fmt.Sprintf("export = %s;", assignDefault)
+	}
+
+	if assignment != "" {
+		footer = append(footer, assignment)
+	} else {
+		specifiers := []string{}
+		for _, name := range r.exportNames(entry, map[*dtsModule]bool{}) {
+			if exportedInPlace[name] {
+				continue
+			}
+			resolved, ok, err := r.resolveExport(entry, name, map[string]bool{})
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return "", fmt.Errorf("could not resolve export %q of %s", name, entry.path)
+			}
+			specifiers = append(specifiers, exportSpecifier(resolved, name))
+		}
+		if len(specifiers) > 0 {
+			footer = append(footer, fmt.Sprintf("export { %s };", strings.Join(specifiers, ", ")))
+		} else {
+			// Keeps the file a module, so hoisted declarations are not exported implicitly
+			footer = append(footer, "export {};")
+		}
+		for _, star := range r.externalStars(entry, map[*dtsModule]bool{}) {
+			footer = append(footer, fmt.Sprintf("export * from %q;", star))
+		}
+	}
+	if asNamespace != "" {
+		footer = append(footer, asNamespace)
+	}
+
+	// Namespaces are declared last, as resolving the exports can add namespaces, including
+	// those of namespaces themselves
+	for i := 0; i < len(r.namespaceOrder); i++ {
+		m := r.namespaceOrder[i]
+		specifiers := []string{}
+		for _, name := range r.exportNames(m, map[*dtsModule]bool{}) {
+			resolved, ok, err := r.resolveExport(m, name, map[string]bool{})
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				specifiers = append(specifiers, exportSpecifier(resolved, name))
+			}
+		}
+		body = append(body, fmt.Sprintf("declare namespace %s {\n    export { %s };\n}", r.namespaces[m], strings.Join(specifiers, ", ")))
+	}
+
+	var header []string
+	header = append(header, r.references...)
+	for _, module := range r.sideEffects {
+		r.checkExternal(module)
+		header = append(header, fmt.Sprintf("import %q;", module))
+	}
+	header = append(header, r.importStatements()...)
+
+	sections := []string{}
+	for _, section := range [][]string{header, body, footer} {
+		if len(section) > 0 {
+			sections = append(sections, strings.Join(section, "\n"))
+		}
+	}
+	return strings.Join(sections, "\n\n") + "\n", nil
+}
+
+// defaultAssignment returns the name to declare with `export =` when the default export is
+// assigned to module.exports, which is only the case when it is the entry's only export. Named
+// exports next to a default export are warned about, as they are merged onto it at runtime only
+// when it has no properties of the same names.
+func (r *declarationRollup) defaultAssignment(entry *dtsModule) (string, error) {
+	if !r.assignDefault {
+		return "", nil
+	}
+	for _, s := range entry.statements {
+		if s.kind == dtsExportAssignment {
+			return "", nil
+		}
+	}
+
+	names := r.exportNames(entry, map[*dtsModule]bool{})
+	if !containsString(names, "default") {
+		return "", nil
+	}
+	if len(names) > 1 || len(r.externalStars(entry, map[*dtsModule]bool{})) > 0 {
+		named := []string{}
+		for _, name := range names {
+			if name != "default" {
+				named = append(named, name)
+			}
+		}
+		if len(named) == 0 {
+			named = append(named, "export *")
+		}
+		r.warnings = append(r.warnings, fmt.Sprintf("the default export has named exports next to it (%s), which require consumers get merged onto it only when it has no properties of the same names, otherwise it stays at require(...).default", strings.Join(named, ", ")))
+		return "", nil
+	}
+
+	name, ok, err := r.resolveExport(entry, "default", map[string]bool{})
+	if err != nil || !ok {
+		return "", err
+	}
+	return name, nil
+}
+
+func (r *declarationRollup) importStatements() []string {
+	statements := []string{}
+	named := make(map[string][]string)
+	modules := []string{}
+
+	for _, imp := range r.externalImports {
+		switch imp.name {
+		case "*":
+			statements = append(statements, fmt.Sprintf("import * as %s from %q;", imp.local, imp.module))
+		case "=":
+			statements = append(statements, fmt.Sprintf("import %s = require(%q);", imp.local, imp.module))
+		case "default":
+			statements = append(statements, fmt.Sprintf("import %s from %q;", imp.local, imp.module))
+		default:
+			if _, ok := named[imp.module]; !ok {
+				modules = append(modules, imp.module)
+			}
+			named[imp.module] = append(named[imp.module], exportSpecifier(imp.name, imp.local))
+		}
+	}
+
+	for _, module := range modules {
+		statements = append(statements, fmt.Sprintf("import { %s } from %q;", strings.Join(named[module], ", "), module))
+	}
+	return statements
+}
+
+func exportSpecifier(local, exported string) string {
+	if local == exported {
+		return local
+	}
+	return local + " as " + exported
+}
+
+// keepsNames reports whether every name a statement declares is hoisted without being renamed.
+func (r *declarationRollup) keepsNames(m *dtsModule, s *dtsStatement) bool {
+	for _, name := range s.names {
+		if m.renames[name] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// rewriteStatement renames identifiers, replaces local import types and strips export modifiers
+// from a declaration unless keepExport is set.
+func (r *declarationRollup) rewriteStatement(m *dtsModule, s *dtsStatement, keepExport bool) ([]dtsToken, error) {
+	tokens := append([]dtsToken{}, s.tokens...)
+
+	if s.kind == dtsDeclaration && !keepExport {
+		tokens = stripExportModifiers(tokens, s, m)
+	}
+
+	var rewriteErr error
+	replaced := make(map[int]int)
+	forEachImportType(tokens, func(specifier string, start, end int) {
+		if !isLocalSpecifier(specifier) || rewriteErr != nil {
+			return
+		}
+		target := m.deps[specifier]
+		dot := nextSignificant(tokens, end)
+		if dot >= 0 && tokens[dot].is(".") {
+			member := nextSignificant(tokens, dot)
+			if member >= 0 && tokens[member].kind == dtsIdent {
+				name, ok, err := r.resolveExport(target, tokens[member].text, map[string]bool{})
+				if err != nil || !ok {
+					rewriteErr = fmt.Errorf("could not resolve import(%q).%s in %s", specifier, tokens[member].text, m.path)
+					return
+				}
+				tokens[start] = dtsToken{dtsIdent, name}
+				replaced[start] = member
+				return
+			}
+		}
+		tokens[start] = dtsToken{dtsIdent, r.namespaceName(target, "")}
+		replaced[start] = end
+	})
+	if rewriteErr != nil {
+		return nil, rewriteErr
+	}
+
+	// names holds the top-level name every reference resolves to
+	scopes := newDtsScopes(tokens)
+	names := make([]string, len(tokens))
+	for i, t := range tokens {
+		if _, ok := replaced[i]; ok {
+			names[i] = t.text
+		} else if t.kind == dtsIdent && isReferencePosition(tokens, scopes, s.keyword, i) {
+			names[i] = t.text
+			if renamed, ok := m.renames[t.text]; ok {
+				names[i] = renamed
+			}
+		}
+	}
+
+	// A type parameter shadowing a top-level name that is referenced in its scope is renamed
+	binderNames := make(map[int]string)
+	for k, b := range scopes.binders {
+		if !b.renamable {
+			continue
+		}
+		for i := b.start; i < b.end && i < len(tokens); i++ {
+			if names[i] == b.name {
+				binderNames[k] = r.uniqueName(b.name)
+				break
+			}
+		}
+	}
+
+	result := make([]dtsToken, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if end, ok := replaced[i]; ok {
+			result = append(result, t)
+			i = end
+			continue
+		}
+		if k := scopes.binding[i]; k >= 0 {
+			if name, ok := binderNames[k]; ok {
+				t.text = name
+			}
+		} else if names[i] != "" {
+			t.text = names[i]
+		}
+		result = append(result, t)
+	}
+
+	return result, nil
+}
+
+// stripExportModifiers turns `export [default] declaration` into a plain ambient declaration,
+// naming anonymous default exports.
+func stripExportModifiers(tokens []dtsToken, s *dtsStatement, m *dtsModule) []dtsToken {
+	if !s.exported {
+		return tokens
+	}
+
+	i := nextSignificant(tokens, -1)
+	for i >= 0 && (tokens[i].is("export") || tokens[i].is("default")) {
+		end := i + 1
+		for end < len(tokens) && tokens[end].kind == dtsSpace {
+			end++
+		}
+		tokens = append(tokens[:i:i], tokens[end:]...)
+	}
+
+	needsDeclare := map[string]bool{
+		"const": true, "let": true, "var": true, "function": true, "class": true,
+		"enum": true, "namespace": true, "module": true, "abstract": true,
+	}
+	if i >= 0 && i < len(tokens) && needsDeclare[tokens[i].text] {
+		tokens = append(tokens[:i:i], append([]dtsToken{{dtsIdent, "declare"}, {dtsSpace, " "}}, tokens[i:]...)...)
+	}
+
+	if s.isDefault && len(s.names) == 0 {
+		for k := i; k < len(tokens); k++ {
+			if tokens[k].is(s.keyword) {
+				name := []dtsToken{{dtsSpace, " "}, {dtsIdent, "_default"}}
+				tokens = append(tokens[:k+1:k+1], append(name, tokens[k+1:]...)...)
+				break
+			}
+		}
+	}
+
+	return tokens
+}
+
+var dtsKeyPrecedingTokens = map[string]bool{
+	"{": true, ";": true, ",": true, "(": true, "[": true, "readonly": true, "static": true, "get": true,
+	"set": true, "public": true, "private": true, "protected": true, "abstract": true, "override": true,
+	"accessor": true,
+}
+
+// isReferencePosition reports whether the identifier at i refers to a top-level binding, as
+// opposed to a member access, property key, parameter name, enum member or a name bound inside
+// the statement.
+func isReferencePosition(tokens []dtsToken, scopes *dtsScopes, keyword string, i int) bool {
+	if scopes.local(i) || scopes.inBindingPattern(tokens, i) {
+		return false
+	}
+
+	prev := prevSignificant(tokens, i)
+	next := nextSignificant(tokens, i)
+
+	if prev >= 0 && tokens[prev].is(".") {
+		// `...T` spreads a type and still references it
+		return prev >= 2 && tokens[prev-1].is(".") && tokens[prev-2].is(".")
+	}
+
+	if keyword == "enum" && prev >= 0 && (tokens[prev].is("{") || tokens[prev].is(",")) {
+		return false
+	}
+
+	if prev < 0 || next < 0 || !isKeyFollower(tokens, scopes, prev, next) {
+		return true
+	}
+
+	if dtsKeyPrecedingTokens[tokens[prev].text] && tokens[prev].kind != dtsString {
+		return false
+	}
+
+	// A member on a new line after a member that omitted its semicolon
+	if hasNewlineBetween(tokens, prev, i) {
+		switch {
+		case tokens[prev].kind == dtsIdent, tokens[prev].is(")"), tokens[prev].is("]"), tokens[prev].is(">"), tokens[prev].is("}"):
+			return false
+		}
+	}
+
+	return true
+}
+
+// isKeyFollower reports whether the token at next can follow a property or method name.
+func isKeyFollower(tokens []dtsToken, scopes *dtsScopes, prev, next int) bool {
+	if tokens[next].kind != dtsPunct {
+		return false
+	}
+
+	switch tokens[next].text {
+	case ":", "(":
+		return true
+	case "?":
+		after := nextSignificant(tokens, next)
+		return after >= 0 && (tokens[after].is(":") || tokens[after].is("("))
+	case ";", "<":
+		// Class properties without a type and generic methods, which otherwise look like type
+		// references. After a comma they are members of a type literal, not type arguments.
+		if tokens[prev].is(",") {
+			open := scopes.enclosing[next]
+			return open >= 0 && tokens[open].is("{")
+		}
+		return tokens[prev].is("{") || tokens[prev].is(";") || tokens[prev].kind == dtsIdent && dtsKeyPrecedingTokens[tokens[prev].text]
+	}
+	return false
+}
+
+func hasNewlineBetween(tokens []dtsToken, from, to int) bool {
+	for k := from + 1; k < to; k++ {
+		if tokens[k].kind == dtsSpace && strings.Contains(tokens[k].text, "\n") {
+			return true
+		}
+	}
+	return false
+}
+
+func joinTokens(tokens []dtsToken) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteString(t.text)
+	}
+	return sb.String()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
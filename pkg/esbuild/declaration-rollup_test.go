@@ -0,0 +1,435 @@
+package esbuild
+
+import (
+	"os"
+	"path/filepath"
+	"squish/internal/config"
+	"testing"
+)
+
+func TestRollupDeclarations(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         map[string]string
+		assignDefault bool
+		want          string
+	}{
+		{
+			name: "names colliding across files",
+			files: map[string]string{
+				"index.d.ts": `import { Options as AOptions } from "./a";
+import { make } from "./b";
+export { wrap } from "./b";
+export interface Options {
+    a: AOptions;
+}
+export declare function run(options: Options): ReturnType<typeof make>;
+`,
+				"a.d.ts": `export interface Options {
+    verbose: boolean;
+}
+`,
+				"b.d.ts": `interface Options {
+    Options: string;
+    retries?: number;
+}
+export declare function make(options: Options): Options;
+export declare function wrap<Options>(value: Options, { Options }: {
+    Options: string;
+}): Options;
+`,
+			},
+			want: `interface Options$1 {
+    verbose: boolean;
+}
+interface Options$2 {
+    Options: string;
+    retries?: number;
+}
+declare function make(options: Options$2): Options$2;
+declare function wrap<Options>(value: Options, { Options }: {
+    Options: string;
+}): Options;
+export interface Options {
+    a: Options$1;
+}
+export declare function run(options: Options): ReturnType<typeof make>;
+
+export { wrap };
+`,
+		},
+		{
+			name: "generic type parameters",
+			files: map[string]string{
+				"index.d.ts": `import { Props } from "./props";
+export type Mapper<Props> = (value: Props) => Props;
+export declare function map<T, Props extends object>(value: T, props: Props): Props;
+export type Keys<T> = { [Props in keyof T]: Props };
+export type Unwrap<T> = T extends Promise<infer Props> ? Props : never;
+export interface Box<Props> {
+    value: Props;
+    with<Props>(props: Props): Box<Props>;
+}
+export declare function render({ Props, size }: Props): Props;
+export type Local = Props;
+`,
+				"props.d.ts": `export interface Props {
+    size: number;
+}
+`,
+			},
+			want: `interface Props {
+    size: number;
+}
+export type Mapper<Props> = (value: Props) => Props;
+export declare function map<T, Props extends object>(value: T, props: Props): Props;
+export type Keys<T> = { [Props in keyof T]: Props };
+export type Unwrap<T> = T extends Promise<infer Props> ? Props : never;
+export interface Box<Props> {
+    value: Props;
+    with<Props>(props: Props): Box<Props>;
+}
+export declare function render({ Props, size }: Props): Props;
+export type Local = Props;
+
+export {};
+`,
+		},
+		{
+			name: "type parameter shadowing a hoisted name",
+			files: map[string]string{
+				"index.d.ts": `import { Props } from "./props";
+export interface Props2 extends Props {}
+export { Props };
+`,
+				"props.d.ts": `import { T } from "./t";
+export interface Props<T> {
+    value: T;
+    other: import("./t").T;
+}
+export { T };
+`,
+				"t.d.ts": `export type T = string;
+`,
+			},
+			want: `type T = string;
+interface Props<T$1> {
+    value: T$1;
+    other: T;
+}
+export interface Props2 extends Props {}
+
+export { Props };
+`,
+		},
+		{
+			name: "type-only imports and exports",
+			files: map[string]string{
+				"index.d.ts": `import type { Shape } from "./shape";
+import { type Color, type Size as Dim } from "./color";
+export type * from "./color";
+export type * as shapes from "./shape";
+export declare const area: (shape: Shape, color: Color, size: Dim) => number;
+`,
+				"color.d.ts": `export type Color = "red" | "blue";
+export type Size = number;
+`,
+				"shape.d.ts": `export interface Shape {
+    sides: number;
+}
+`,
+			},
+			want: `interface Shape {
+    sides: number;
+}
+type Color = "red" | "blue";
+type Size = number;
+export declare const area: (shape: Shape, color: Color, size: Size) => number;
+declare namespace shapes {
+    export { Shape };
+}
+
+export { shapes, Color, Size };
+`,
+		},
+		{
+			name: "namespaces",
+			files: map[string]string{
+				"index.d.ts": `import { Tree } from "./tree";
+export interface Node {
+    entry: boolean;
+}
+export { Tree };
+`,
+				"node.d.ts": `export interface Node {
+    id: string;
+}
+`,
+				"tree.d.ts": `import { Node } from "./node";
+export declare namespace Tree {
+    interface Node {
+        children: Node[];
+    }
+    function walk(node: Node): void;
+    function from(node: import("./node").Node): Node;
+    const enum Kind {
+        Node = 0,
+        Leaf = 1
+    }
+}
+export declare function toTree(node: Node): Tree.Node;
+`,
+			},
+			want: `interface Node$1 {
+    id: string;
+}
+declare namespace Tree {
+    interface Node {
+        children: Node[];
+    }
+    function walk(node: Node): void;
+    function from(node: Node$1): Node;
+    const enum Kind {
+        Node = 0,
+        Leaf = 1
+    }
+}
+declare function toTree(node: Node$1): Tree.Node;
+export interface Node {
+    entry: boolean;
+}
+
+export { Tree };
+`,
+		},
+		{
+			name: "export =",
+			files: map[string]string{
+				"index.d.ts": `import { Options } from "./options";
+declare function create(options: Options): void;
+declare namespace create {
+    var version: string;
+}
+export = create;
+`,
+				"options.d.ts": `export interface Options {
+    debug: boolean;
+}
+`,
+			},
+			want: `interface Options {
+    debug: boolean;
+}
+declare function create(options: Options): void;
+declare namespace create {
+    var version: string;
+}
+
+export = create;
+`,
+		},
+		{
+			name: "default export as export =",
+			files: map[string]string{
+				"index.d.ts": `import { Options } from "./options";
+declare function create(options: Options): void;
+export default create;
+`,
+				"options.d.ts": `export interface Options {
+    debug: boolean;
+}
+`,
+			},
+			assignDefault: true,
+			want: `interface Options {
+    debug: boolean;
+}
+declare function create(options: Options): void;
+
+export = create;
+`,
+		},
+		{
+			name: "declare global",
+			files: map[string]string{
+				"index.d.ts": `import "./config";
+export interface Config {
+    entry: boolean;
+}
+`,
+				"config.d.ts": `interface Config {
+    url: string;
+}
+declare global {
+    interface Window {
+        config: Config;
+    }
+    var Config: Config;
+    function configure(config: typeof Config): void;
+}
+export {};
+`,
+			},
+			want: `interface Config$1 {
+    url: string;
+}
+declare global {
+    interface Window {
+        config: Config$1;
+    }
+    var Config: Config$1;
+    function configure(config: typeof Config): void;
+}
+export interface Config {
+    entry: boolean;
+}
+
+export {};
+`,
+		},
+		{
+			name: "statements without semicolons",
+			files: map[string]string{
+				"index.d.ts": `import { Handler } from "./handler"
+export declare const handlers: Handler[]
+export type Callback = (
+    value: string
+) => void
+export declare function on(event: string,
+    handler: Handler): void
+export interface Events {
+    start: Callback
+    stop(): void
+}
+`,
+				"handler.d.ts": `export type Handler = () => void
+declare const version: string
+export { version }
+`,
+			},
+			want: `type Handler = () => void
+declare const version: string
+export declare const handlers: Handler[]
+export type Callback = (
+    value: string
+) => void
+export declare function on(event: string,
+    handler: Handler): void
+export interface Events {
+    start: Callback
+    stop(): void
+}
+
+export {};
+`,
+		},
+		{
+			name: "external imports",
+			files: map[string]string{
+				"index.d.ts": `/// <reference types="node" />
+import { EventEmitter } from "events";
+import * as fs from "node:fs";
+import { Bus } from "./bus";
+export declare class Store extends EventEmitter {
+    bus: Bus;
+    stats: fs.Stats;
+}
+`,
+				"bus.d.ts": `import { EventEmitter as Emitter } from "events";
+export type Bus = Emitter;
+`,
+			},
+			want: `/// <reference types="node" />
+import * as fs from "node:fs";
+import { EventEmitter as Emitter } from "events";
+
+type Bus = Emitter;
+export declare class Store extends Emitter {
+    bus: Bus;
+    stats: fs.Stats;
+}
+
+export {};
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeDeclarations(t, tt.files)
+			got, warnings, err := rollupDeclarations(filepath.Join(dir, "index.d.ts"), []string{dir}, func(string) bool { return true }, tt.assignDefault)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(warnings) > 0 {
+				t.Errorf("unexpected warnings %q", warnings)
+			}
+			if got != tt.want {
+				t.Errorf("rollupDeclarations() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollupDeclarationsFailsOnUndeclaredImports(t *testing.T) {
+	dir := writeDeclarations(t, map[string]string{
+		"index.d.ts": `import { EventEmitter } from "events";
+import * as fs from "node:fs";
+export * from "zod";
+export declare class Store extends EventEmitter {
+    stats: fs.Stats;
+    other: EventEmitter;
+}
+`,
+	})
+	isExternal := func(path string) bool { return path != "events" && path != "zod" }
+	_, _, err := rollupDeclarations(filepath.Join(dir, "index.d.ts"), []string{dir}, isExternal, false)
+	want := `declarations import packages that are not listed in dependencies or peerDependencies, so their types do not resolve for consumers: "events", "zod"`
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
+
+func TestIsExternalDeclarationImport(t *testing.T) {
+	b := NewBundler(&BundlerConfig{}, &config.PackageJSON{
+		Dependencies:     map[string]string{"react": "^18", "@types/lodash": "^4", "@types/babel__core": "^7"},
+		PeerDependencies: map[string]string{"@acme/ui": "*"},
+		DevDependencies:  map[string]string{"zod": "^3", "@types/express": "^4"},
+	})
+
+	tests := []struct {
+		specifier string
+		want      bool
+	}{
+		{specifier: "react", want: true},
+		{specifier: "react/jsx-runtime", want: true},
+		{specifier: "@acme/ui", want: true},
+		{specifier: "@acme/ui/button", want: true},
+		{specifier: "lodash", want: true},
+		{specifier: "lodash/merge", want: true},
+		{specifier: "@babel/core", want: true},
+		{specifier: "node:fs", want: true},
+		{specifier: "events", want: true},
+		{specifier: "zod", want: false},
+		{specifier: "express", want: false},
+		{specifier: "@acme/other", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.specifier, func(t *testing.T) {
+			if got := b.isExternalDeclarationImport(tt.specifier); got != tt.want {
+				t.Errorf("isExternalDeclarationImport(%q) = %v, want %v", tt.specifier, got, tt.want)
+			}
+		})
+	}
+}
+
+func writeDeclarations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,935 @@
+package esbuild
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The declaration scanner splits .d.ts files into top-level statements. It only understands as much
+// TypeScript as tsc emits into declaration files: no expressions, no function bodies.
+
+type dtsTokenKind int
+
+const (
+	dtsIdent dtsTokenKind = iota
+	dtsString
+	dtsTemplate
+	dtsNumber
+	dtsPunct
+	dtsComment
+	dtsSpace
+)
+
+type dtsToken struct {
+	kind dtsTokenKind
+	text string
+}
+
+func (t dtsToken) significant() bool {
+	return t.kind != dtsSpace && t.kind != dtsComment
+}
+
+func (t dtsToken) is(text string) bool {
+	return t.kind != dtsString && t.kind != dtsComment && t.text == text
+}
+
+type dtsStatementKind int
+
+const (
+	dtsDeclaration dtsStatementKind = iota
+	dtsImport
+	dtsExportFrom
+	dtsExportList
+	dtsExportDefaultName
+	dtsExportAssignment
+	dtsExportAsNamespace
+	dtsAmbient
+)
+
+type dtsSpecifier struct {
+	// For imports name is the imported name and alias the local binding,
+	// for exports name is the local binding and alias the exported name.
+	name  string
+	alias string
+}
+
+type dtsStatement struct {
+	tokens []dtsToken
+
+	kind       dtsStatementKind
+	module     string
+	specifiers []dtsSpecifier
+	star       bool
+	// defaultImport, namespace and require hold local bindings for import statements;
+	// namespace also holds the exported name of `export * as ns from`.
+	defaultImport string
+	namespace     string
+	require       string
+	name          string
+
+	keyword   string
+	names     []string
+	exported  bool
+	isDefault bool
+}
+
+var dtsStatementKeywords = map[string]bool{
+	"export": true, "import": true, "declare": true, "interface": true, "type": true, "class": true,
+	"enum": true, "namespace": true, "module": true, "function": true, "const": true, "let": true,
+	"var": true, "abstract": true, "global": true,
+}
+
+var dtsContinuationTokens = map[string]bool{
+	"=": true, "|": true, "&": true, ",": true, ":": true, "?": true, "<": true, "(": true, ".": true,
+	"extends": true, "implements": true, "keyof": true, "typeof": true, "infer": true, "is": true,
+	"as": true, "in": true, "new": true, "readonly": true, "unique": true, "declare": true,
+	"export": true, "default": true, "abstract": true, "asserts": true,
+}
+
+var dtsBlockKeywords = map[string]bool{
+	"interface": true, "class": true, "namespace": true, "module": true, "enum": true, "global": true,
+}
+
+var dtsModifiers = map[string]bool{
+	"export": true, "default": true, "declare": true, "abstract": true, "async": true,
+}
+
+func tokenizeDeclarations(src string) []dtsToken {
+	tokens := []dtsToken{}
+	// templateDepths records the brace depth at which each open template literal hole started
+	templateDepths := []int{}
+	depth := 0
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			for i < len(src) && (src[i] == ' ' || src[i] == '\t' || src[i] == '\n' || src[i] == '\r') {
+				i++
+			}
+			tokens = append(tokens, dtsToken{dtsSpace, src[start:i]})
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			tokens = append(tokens, dtsToken{dtsComment, src[start:i]})
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end + 4
+			}
+			tokens = append(tokens, dtsToken{dtsComment, src[start:i]})
+
+		case c == '"' || c == '\'':
+			i++
+			for i < len(src) && src[i] != c && src[i] != '\n' {
+				if src[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i < len(src) {
+				i++
+			}
+			tokens = append(tokens, dtsToken{dtsString, src[start:min(i, len(src))]})
+
+		case c == '`':
+			var hole bool
+			i, hole = scanTemplateChunk(src, i+1)
+			if hole {
+				templateDepths = append(templateDepths, depth)
+			}
+			tokens = append(tokens, dtsToken{dtsTemplate, src[start:i]})
+
+		case c == '}' && len(templateDepths) > 0 && templateDepths[len(templateDepths)-1] == depth:
+			templateDepths = templateDepths[:len(templateDepths)-1]
+			var hole bool
+			i, hole = scanTemplateChunk(src, i+1)
+			if hole {
+				templateDepths = append(templateDepths, depth)
+			}
+			tokens = append(tokens, dtsToken{dtsTemplate, src[start:i]})
+
+		case c >= '0' && c <= '9':
+			for i < len(src) && (isIdentPart(src[i]) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, dtsToken{dtsNumber, src[start:i]})
+
+		case isIdentStart(src[i:]):
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if r != '$' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, dtsToken{dtsIdent, src[start:i]})
+
+		default:
+			switch c {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			i++
+			tokens = append(tokens, dtsToken{dtsPunct, src[start:i]})
+		}
+	}
+
+	return tokens
+}
+
+// scanTemplateChunk scans template literal text starting at i and returns the index after the
+// chunk and whether it ended by opening a `${` hole rather than closing the literal.
+func scanTemplateChunk(src string, i int) (int, bool) {
+	for i < len(src) {
+		switch {
+		case src[i] == '\\':
+			i += 2
+		case src[i] == '`':
+			return i + 1, false
+		case src[i] == '$' && i+1 < len(src) && src[i+1] == '{':
+			return i + 2, true
+		default:
+			i++
+		}
+	}
+	return len(src), false
+}
+
+func isIdentStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '$' || r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentPart(c byte) bool {
+	return c == '$' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// splitDeclarationStatements groups tokens into top-level statements. Leading comments and
+// whitespace belong to the statement that follows them.
+func splitDeclarationStatements(tokens []dtsToken) []*dtsStatement {
+	statements := []*dtsStatement{}
+	start := 0
+	depth := 0
+	angle := 0
+	bodyOpened := false
+
+	flush := func(end int) {
+		statement := &dtsStatement{tokens: tokens[start:end]}
+		analyzeStatement(statement)
+		statements = append(statements, statement)
+		start = end
+		depth = 0
+		angle = 0
+		bodyOpened = false
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+
+		if t.kind == dtsSpace && depth == 0 && angle == 0 && strings.Contains(t.text, "\n") {
+			if endsAtNewline(tokens, start, i) {
+				flush(i)
+			}
+			continue
+		}
+
+		if t.kind != dtsPunct {
+			continue
+		}
+
+		switch t.text {
+		case "{":
+			if depth == 0 && angle == 0 {
+				bodyOpened = true
+			}
+			depth++
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+		case "}":
+			depth--
+			if depth == 0 && bodyOpened && dtsBlockKeywords[statementKeyword(tokens[start:i+1])] {
+				flush(i + 1)
+			}
+		case "<":
+			if depth == 0 {
+				angle++
+			}
+		case ">":
+			if depth == 0 && angle > 0 && !(i > 0 && tokens[i-1].is("=")) {
+				angle--
+			}
+		case ";":
+			if depth == 0 {
+				flush(i + 1)
+			}
+		}
+	}
+
+	for _, t := range tokens[start:] {
+		if t.significant() {
+			flush(len(tokens))
+			break
+		}
+	}
+
+	return statements
+}
+
+// endsAtNewline applies automatic semicolon insertion for hand-written declaration files that
+// omit semicolons: a newline ends the statement when the next line starts a new declaration.
+func endsAtNewline(tokens []dtsToken, start, i int) bool {
+	last := prevSignificant(tokens, i)
+	if last < start {
+		return false
+	}
+	next := nextSignificant(tokens, i)
+	if next < 0 || tokens[next].kind != dtsIdent || !dtsStatementKeywords[tokens[next].text] {
+		return false
+	}
+	if tokens[last].kind != dtsString && dtsContinuationTokens[tokens[last].text] {
+		return false
+	}
+	// Arrow function types continue on the next line
+	if tokens[last].is(">") && last > 0 && tokens[last-1].is("=") {
+		return false
+	}
+	return true
+}
+
+func statementKeyword(tokens []dtsToken) string {
+	for _, t := range tokens {
+		if !t.significant() {
+			continue
+		}
+		if t.kind == dtsIdent && dtsModifiers[t.text] {
+			continue
+		}
+		return t.text
+	}
+	return ""
+}
+
+func prevSignificant(tokens []dtsToken, i int) int {
+	for i--; i >= 0; i-- {
+		if tokens[i].significant() {
+			return i
+		}
+	}
+	return -1
+}
+
+func nextSignificant(tokens []dtsToken, i int) int {
+	for i++; i < len(tokens); i++ {
+		if tokens[i].significant() {
+			return i
+		}
+	}
+	return -1
+}
+
+// dtsCursor walks the significant tokens of a statement.
+type dtsCursor struct {
+	tokens []dtsToken
+	pos    int
+}
+
+func newDtsCursor(tokens []dtsToken) *dtsCursor {
+	significant := []dtsToken{}
+	for _, t := range tokens {
+		if t.significant() {
+			significant = append(significant, t)
+		}
+	}
+	return &dtsCursor{tokens: significant}
+}
+
+func (c *dtsCursor) peek(offset int) dtsToken {
+	if c.pos+offset < len(c.tokens) {
+		return c.tokens[c.pos+offset]
+	}
+	return dtsToken{kind: dtsPunct}
+}
+
+func (c *dtsCursor) next() dtsToken {
+	t := c.peek(0)
+	c.pos++
+	return t
+}
+
+func (c *dtsCursor) accept(text string) bool {
+	if c.peek(0).is(text) {
+		c.pos++
+		return true
+	}
+	return false
+}
+
+func (c *dtsCursor) done() bool {
+	return c.pos >= len(c.tokens) || c.peek(0).is(";")
+}
+
+// specifiers parses a `{ a, type b as c }` clause. The opening brace must already be consumed.
+func (c *dtsCursor) specifiers() []dtsSpecifier {
+	specifiers := []dtsSpecifier{}
+	for c.pos < len(c.tokens) && !c.accept("}") {
+		if c.accept(",") {
+			continue
+		}
+		if c.peek(0).is("type") && !c.peek(1).is(",") && !c.peek(1).is("}") && !c.peek(1).is("as") {
+			c.pos++
+		}
+		name := c.next()
+		if name.kind == dtsString {
+			name.text = unquoteDtsString(name.text)
+		}
+		alias := name.text
+		if c.accept("as") {
+			alias = c.next().text
+		}
+		specifiers = append(specifiers, dtsSpecifier{name: name.text, alias: alias})
+	}
+	return specifiers
+}
+
+func (c *dtsCursor) moduleSpecifier() string {
+	t := c.next()
+	if t.kind != dtsString {
+		return ""
+	}
+	return unquoteDtsString(t.text)
+}
+
+func unquoteDtsString(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	if unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(s[1:len(s)-1], `"`, `\"`) + `"`); err == nil {
+		return unquoted
+	}
+	return s[1 : len(s)-1]
+}
+
+func analyzeStatement(s *dtsStatement) {
+	c := newDtsCursor(s.tokens)
+
+	switch {
+	case c.accept("import"):
+		analyzeImport(s, c)
+	case c.accept("export"):
+		analyzeExport(s, c)
+	default:
+		analyzeDeclaration(s, c)
+	}
+}
+
+func analyzeImport(s *dtsStatement, c *dtsCursor) {
+	s.kind = dtsImport
+
+	if c.peek(0).kind == dtsString {
+		s.module = c.moduleSpecifier()
+		return
+	}
+
+	if c.peek(0).is("type") && !c.peek(1).is("from") && !c.peek(1).is("=") && !c.peek(1).is(",") {
+		c.pos++
+	}
+
+	if c.peek(0).kind == dtsIdent && c.peek(1).is("=") {
+		name := c.next().text
+		c.pos++
+		if c.accept("require") && c.accept("(") {
+			s.require = name
+			s.module = c.moduleSpecifier()
+			return
+		}
+		// `import X = A.B` is an alias declaration rather than a module import
+		s.kind = dtsDeclaration
+		s.keyword = "import"
+		s.names = []string{name}
+		return
+	}
+
+	if (c.peek(0).kind == dtsIdent && !c.peek(0).is("from")) || (c.peek(0).is("from") && c.peek(1).is("from")) {
+		s.defaultImport = c.next().text
+		c.accept(",")
+	}
+
+	if c.accept("*") {
+		c.accept("as")
+		s.namespace = c.next().text
+	} else if c.accept("{") {
+		s.specifiers = c.specifiers()
+	}
+
+	if c.accept("from") {
+		s.module = c.moduleSpecifier()
+	}
+}
+
+func analyzeExport(s *dtsStatement, c *dtsCursor) {
+	// `export type * from` re-exports types only, which is all declarations have
+	if c.peek(0).is("type") && c.peek(1).is("*") {
+		c.pos++
+	}
+
+	switch {
+	case c.accept("*"):
+		s.kind = dtsExportFrom
+		s.star = true
+		if c.accept("as") {
+			s.namespace = c.next().text
+		}
+		c.accept("from")
+		s.module = c.moduleSpecifier()
+		return
+
+	case c.peek(0).is("{") || c.peek(0).is("type") && c.peek(1).is("{"):
+		c.accept("type")
+		c.accept("{")
+		s.specifiers = c.specifiers()
+		s.kind = dtsExportList
+		if c.accept("from") {
+			s.kind = dtsExportFrom
+			s.module = c.moduleSpecifier()
+		}
+		return
+
+	case c.accept("="):
+		s.kind = dtsExportAssignment
+		s.name = c.next().text
+		return
+
+	case c.peek(0).is("as") && c.peek(1).is("namespace"):
+		c.pos += 2
+		s.kind = dtsExportAsNamespace
+		s.name = c.next().text
+		return
+
+	case c.peek(0).is("default") && c.peek(1).kind == dtsIdent && !dtsStatementKeywords[c.peek(1).text] &&
+		(c.pos+2 >= len(c.tokens) || c.peek(2).is(";")):
+		s.kind = dtsExportDefaultName
+		s.name = c.peek(1).text
+		return
+	}
+
+	s.exported = true
+	analyzeDeclaration(s, c)
+}
+
+func analyzeDeclaration(s *dtsStatement, c *dtsCursor) {
+	s.kind = dtsDeclaration
+
+	for c.peek(0).kind == dtsIdent && dtsModifiers[c.peek(0).text] {
+		if c.next().text == "default" {
+			s.isDefault = true
+		}
+	}
+
+	keyword := c.next()
+	s.keyword = keyword.text
+
+	switch keyword.text {
+	case "const", "let", "var":
+		if c.accept("enum") {
+			s.keyword = "enum"
+			s.names = declarationName(c)
+			return
+		}
+		s.names = declaratorNames(c)
+
+	case "function", "class", "interface", "type", "enum":
+		s.names = declarationName(c)
+
+	case "namespace", "module":
+		if c.peek(0).kind == dtsString {
+			s.kind = dtsAmbient
+			return
+		}
+		s.names = declarationName(c)
+
+	case "global":
+		s.kind = dtsAmbient
+
+	case "import":
+		if c.peek(0).kind == dtsIdent {
+			s.names = []string{c.peek(0).text}
+		}
+	}
+}
+
+func declarationName(c *dtsCursor) []string {
+	t := c.peek(0)
+	if t.kind != dtsIdent || t.is("extends") || t.is("implements") {
+		return nil
+	}
+	return []string{t.text}
+}
+
+// declaratorNames returns the names declared by `const a: A, b: B`.
+func declaratorNames(c *dtsCursor) []string {
+	names := []string{}
+	depth := 0
+	expectName := true
+
+	for ; c.pos < len(c.tokens); c.pos++ {
+		t := c.tokens[c.pos]
+		if expectName && t.kind == dtsIdent {
+			names = append(names, t.text)
+			expectName = false
+			continue
+		}
+		switch t.text {
+		case "{", "(", "[", "<":
+			depth++
+		case "}", ")", "]":
+			depth--
+		case ">":
+			if c.pos > 0 && !c.tokens[c.pos-1].is("=") {
+				depth--
+			}
+		case ",":
+			if depth == 0 {
+				expectName = true
+			}
+		}
+	}
+
+	return names
+}
+
+// dtsScopes holds the brackets and the names bound inside a statement
+type dtsScopes struct {
+	binders []dtsBinder
+	// binding is the index of the binder an identifier refers to, or -1 for identifiers that are
+	// not bound inside the statement
+	binding []int
+	// enclosing is the index of the innermost bracket around every token, -1 at the top level
+	enclosing []int
+}
+
+func newDtsScopes(tokens []dtsToken) *dtsScopes {
+	depths := tokenDepths(tokens)
+	scopes := &dtsScopes{
+		binders:   statementBinders(tokens, depths),
+		binding:   make([]int, len(tokens)),
+		enclosing: make([]int, len(tokens)),
+	}
+
+	for i := range scopes.binding {
+		scopes.binding[i] = -1
+	}
+	// Binders are found in order, so inner scopes come after the scopes around them
+	for k, b := range scopes.binders {
+		for i := b.start; i < b.end && i < len(tokens); i++ {
+			if tokens[i].kind != dtsIdent || tokens[i].text != b.name {
+				continue
+			}
+			if b.value && i != b.decl {
+				if p := prevSignificant(tokens, i); p < 0 || !tokens[p].is("typeof") {
+					continue
+				}
+			}
+			scopes.binding[i] = k
+		}
+	}
+
+	stack := []int{}
+	for i, t := range tokens {
+		if t.kind == dtsPunct {
+			switch {
+			case t.is("}"), t.is(")"), t.is("]"), t.is(">") && !(i > 0 && tokens[i-1].is("=")):
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			}
+		}
+		scopes.enclosing[i] = -1
+		if len(stack) > 0 {
+			scopes.enclosing[i] = stack[len(stack)-1]
+		}
+		if t.is("{") || t.is("(") || t.is("[") || t.is("<") {
+			stack = append(stack, i)
+		}
+	}
+	return scopes
+}
+
+// local reports whether the identifier at i refers to a name bound inside the statement: a type
+// parameter, infer declaration, mapped type key, enum member or a member of a namespace, global
+// or ambient module body. These shadow top-level names and are not renamed with them.
+func (s *dtsScopes) local(i int) bool {
+	return s.binding[i] >= 0
+}
+
+// inBindingPattern reports whether the token at i is in a destructuring pattern of a parameter,
+// e.g. `{ a, b }` in `f({ a, b }: Props)`, whose names are property and parameter names.
+func (s *dtsScopes) inBindingPattern(tokens []dtsToken, i int) bool {
+	open := s.enclosing[i]
+	if open < 0 || !(tokens[open].is("{") || tokens[open].is("[")) {
+		return false
+	}
+	outer := s.enclosing[open]
+	before := prevSignificant(tokens, open)
+	return outer >= 0 && tokens[outer].is("(") && before >= 0 && (tokens[before].is("(") || tokens[before].is(","))
+}
+
+// dtsBinder is a name bound inside a statement, which shadows top-level names from start to end
+type dtsBinder struct {
+	name       string
+	start, end int
+	// decl is the index of the identifier binding the name
+	decl int
+	// value binders only bind a value, which shadows top-level names in typeof queries only
+	value bool
+	// renamable binders are type parameters and infer declarations, which can be renamed within
+	// their scope when a top-level name they shadow is referenced there
+	renamable bool
+}
+
+// tokenDepths returns the bracket nesting depth of every token. Brackets have the depth of the
+// tokens around them, the tokens between them one more.
+func tokenDepths(tokens []dtsToken) []int {
+	depths := make([]int, len(tokens))
+	depth := 0
+	for i, t := range tokens {
+		if t.kind != dtsPunct {
+			depths[i] = depth
+			continue
+		}
+		switch t.text {
+		case "{", "(", "[", "<":
+			depths[i] = depth
+			depth++
+		case "}", ")", "]":
+			depth--
+			depths[i] = depth
+		case ">":
+			// The > of an arrow function type does not close a type argument list
+			if i > 0 && tokens[i-1].is("=") {
+				depths[i] = depth
+				continue
+			}
+			depth--
+			depths[i] = depth
+		default:
+			depths[i] = depth
+		}
+	}
+	return depths
+}
+
+// groupEnd returns the index of the bracket closing the one opened at open.
+func groupEnd(tokens []dtsToken, depths []int, open int) int {
+	for j := open + 1; j < len(tokens); j++ {
+		if depths[j] <= depths[open] && tokens[j].kind == dtsPunct {
+			return j
+		}
+	}
+	return len(tokens)
+}
+
+// listEnd returns the index of the first token after from that is at the depth of from and one of
+// stops, or that closes the group from is in.
+func listEnd(tokens []dtsToken, depths []int, from int, stops ...string) int {
+	for j := from + 1; j < len(tokens); j++ {
+		if depths[j] < depths[from] {
+			return j
+		}
+		if depths[j] == depths[from] && tokens[j].kind == dtsPunct && containsString(stops, tokens[j].text) {
+			return j
+		}
+	}
+	return len(tokens)
+}
+
+var dtsMemberKeywords = map[string]bool{
+	"const": true, "let": true, "var": true, "function": true, "class": true, "interface": true,
+	"type": true, "enum": true, "namespace": true, "module": true,
+}
+
+func statementBinders(tokens []dtsToken, depths []int) []dtsBinder {
+	binders := []dtsBinder{}
+
+	for i, t := range tokens {
+		if !t.significant() {
+			continue
+		}
+		prev := prevSignificant(tokens, i)
+		next := nextSignificant(tokens, i)
+
+		switch {
+		case t.is("<"):
+			binders = append(binders, typeParameterBinders(tokens, depths, i)...)
+
+		case t.is("infer") && next >= 0 && tokens[next].kind == dtsIdent:
+			binders = append(binders, dtsBinder{name: tokens[next].text, start: i, end: inferScopeEnd(tokens, depths, i), decl: next, renamable: true})
+
+		case t.kind == dtsIdent && prev >= 0 && tokens[prev].is("[") && next >= 0 && tokens[next].is("in"):
+			binders = append(binders, dtsBinder{name: t.text, start: i, end: listEnd(tokens, depths, prev), decl: i, renamable: true})
+
+		case t.is("{"):
+			binders = append(binders, bodyBinders(tokens, depths, i)...)
+		}
+	}
+	return binders
+}
+
+// typeParameterBinders returns the type parameters of the list opened at open, if it is a type
+// parameter list rather than type arguments. Type parameters of classes, interfaces, functions and
+// type aliases are in scope for the rest of the declaration, those of call signatures, methods
+// and function types for the rest of the signature.
+func typeParameterBinders(tokens []dtsToken, depths []int, open int) []dtsBinder {
+	closing := groupEnd(tokens, depths, open)
+	name := prevSignificant(tokens, open)
+	keyword := prevSignificant(tokens, name)
+
+	end := -1
+	switch {
+	case name >= 0 && tokens[name].kind == dtsIdent && keyword >= 0 && (tokens[keyword].is("class") || tokens[keyword].is("interface")):
+		end = len(tokens)
+		for j := closing + 1; j < len(tokens); j++ {
+			if depths[j] == depths[open] && tokens[j].is("{") {
+				end = groupEnd(tokens, depths, j) + 1
+				break
+			}
+		}
+	case name >= 0 && tokens[name].kind == dtsIdent && keyword >= 0 && (tokens[keyword].is("function") || tokens[keyword].is("type")):
+		end = listEnd(tokens, depths, open, ";")
+	default:
+		if after := nextSignificant(tokens, closing); after >= 0 && tokens[after].is("(") {
+			end = listEnd(tokens, depths, open, ";", ",")
+		}
+	}
+	if end < 0 {
+		return nil
+	}
+
+	binders := []dtsBinder{}
+	for j := open + 1; j < closing; j++ {
+		if tokens[j].kind != dtsIdent || depths[j] != depths[open]+1 {
+			continue
+		}
+		before := prevSignificant(tokens, j)
+		after := nextSignificant(tokens, j)
+		if !(tokens[before].is("<") || tokens[before].is(",") || tokens[before].is("in") || tokens[before].is("out") || tokens[before].is("const")) {
+			continue
+		}
+		if after >= 0 && (tokens[after].is(",") || tokens[after].is(">") || tokens[after].is("extends") || tokens[after].is("=")) {
+			binders = append(binders, dtsBinder{name: tokens[j].text, start: open, end: end, decl: j, renamable: true})
+		}
+	}
+	return binders
+}
+
+// inferScopeEnd returns the end of the true branch of the conditional type an infer declaration
+// at i belongs to.
+func inferScopeEnd(tokens []dtsToken, depths []int, i int) int {
+	j := i + 1
+	for ; j < len(tokens); j++ {
+		if depths[j] > depths[i] || !tokens[j].is("?") {
+			continue
+		}
+		// Optional properties and methods are not the ? of the conditional type
+		if after := nextSignificant(tokens, j); after >= 0 && (tokens[after].is(":") || tokens[after].is("(")) {
+			continue
+		}
+		break
+	}
+	if j >= len(tokens) {
+		return len(tokens)
+	}
+
+	question := j
+	nested := 0
+	for j++; j < len(tokens); j++ {
+		if depths[j] < depths[question] {
+			break
+		}
+		if depths[j] > depths[question] {
+			continue
+		}
+		if tokens[j].is("?") {
+			nested++
+		} else if tokens[j].is(":") {
+			if nested == 0 {
+				break
+			}
+			nested--
+		} else if tokens[j].is(";") || tokens[j].is(",") {
+			break
+		}
+	}
+	return j
+}
+
+// bodyBinders returns the names declared in the body opened at open when it is the body of a
+// namespace, global or ambient module declaration, or the members of an enum body.
+func bodyBinders(tokens []dtsToken, depths []int, open int) []dtsBinder {
+	end := groupEnd(tokens, depths, open)
+	k := prevSignificant(tokens, open)
+	if k < 0 {
+		return nil
+	}
+
+	binders := []dtsBinder{}
+	switch {
+	case tokens[k].kind == dtsIdent && prevSignificant(tokens, k) >= 0 && tokens[prevSignificant(tokens, k)].is("enum"):
+		for j := open + 1; j < end; j++ {
+			if tokens[j].kind == dtsIdent && depths[j] == depths[open]+1 {
+				if before := prevSignificant(tokens, j); tokens[before].is("{") || tokens[before].is(",") {
+					binders = append(binders, dtsBinder{name: tokens[j].text, start: open, end: end, decl: j})
+				}
+			}
+		}
+		return binders
+
+	case tokens[k].is("global"):
+	case tokens[k].kind == dtsString && prevSignificant(tokens, k) >= 0 && tokens[prevSignificant(tokens, k)].is("module"):
+	default:
+		// namespace A.B.C {
+		for tokens[k].kind == dtsIdent {
+			before := prevSignificant(tokens, k)
+			if before < 0 {
+				return nil
+			}
+			if tokens[before].is("namespace") || tokens[before].is("module") {
+				break
+			}
+			if !tokens[before].is(".") {
+				return nil
+			}
+			k = prevSignificant(tokens, before)
+			if k < 0 {
+				return nil
+			}
+		}
+		if tokens[k].kind != dtsIdent {
+			return nil
+		}
+	}
+
+	for j := open + 1; j < end; j++ {
+		if depths[j] != depths[open]+1 || tokens[j].kind != dtsIdent || !dtsMemberKeywords[tokens[j].text] {
+			continue
+		}
+		if name := nextSignificant(tokens, j); name >= 0 && name < end && tokens[name].kind == dtsIdent {
+			value := tokens[j].is("const") || tokens[j].is("let") || tokens[j].is("var") || tokens[j].is("function")
+			binders = append(binders, dtsBinder{name: tokens[name].text, start: open, end: end, decl: name, value: value})
+		}
+	}
+	return binders
+}
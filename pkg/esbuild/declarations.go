@@ -0,0 +1,278 @@
+package esbuild
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"squish/internal/config"
+	"squish/internal/utils"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/fatih/color"
+)
+
+// declarationExtensions maps a TypeScript source extension to the declaration extension tsc emits for it.
+var declarationExtensions = map[string]string{
+	".ts":  ".d.ts",
+	".tsx": ".d.ts",
+	".mts": ".d.mts",
+	".cts": ".d.cts",
+}
+
+// declarationOutputs identifies the declaration build among the builds of a bundle
+const declarationOutputs = "declarations"
+
+type declarationEntry struct {
+	entry      config.ExportEntry
+	sourcePath *utils.SourcePathResult
+}
+
+func (b *Bundler) generateDeclarations(w io.Writer, entries []config.ExportEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	declarationEntries := make([]declarationEntry, 0, len(entries))
+	inputs := make([]string, 0, len(entries))
+	seenInputs := make(map[string]bool)
+	for _, entry := range entries {
+		sourcePath, err := utils.GetSourcePath(entry, b.config.SrcDir, b.config.DistDir)
+		if err != nil {
+			return fmt.Errorf("error resolving source path: %w", err)
+		}
+		declarationEntries = append(declarationEntries, declarationEntry{entry: entry, sourcePath: sourcePath})
+		// Hand-written declarations are not emitted by tsc, so there is nothing to compile for them
+		if !seenInputs[sourcePath.Input] && !strings.HasPrefix(sourcePath.SrcExtension, ".d.") {
+			seenInputs[sourcePath.Input] = true
+			inputs = append(inputs, sourcePath.Input)
+		}
+	}
+
+	tmpDir, err := os.MkdirTemp("", "squish-dts-")
+	if err != nil {
+		return fmt.Errorf("failed to create declaration directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if len(inputs) > 0 {
+		if err := b.buildReferences(w); err != nil {
+			return err
+		}
+		if err := utils.RunTSC(w, inputs, tmpDir, b.getTsconfigPath()); err != nil {
+			var tscErr *utils.TSCError
+			if errors.As(err, &tscErr) {
+				printTSCDiagnostics(w, tscErr)
+			}
+			return fmt.Errorf("failed to generate declarations: %w", err)
+		}
+		if err := b.rewriteDeclarationAliases(tmpDir, declarationEntries); err != nil {
+			return fmt.Errorf("failed to resolve tsconfig paths in declarations: %w", err)
+		}
+	}
+
+	if b.config.Unbundled {
+		written, err := b.placeUnbundledDeclarations(tmpDir, declarationEntries)
+		if err != nil {
+			return err
+		}
+		b.recordWritten(declarationOutputs, written)
+		return nil
+	}
+
+	written := make([]string, 0, len(declarationEntries))
+	for _, d := range declarationEntries {
+		outfile, err := b.placeDeclaration(w, tmpDir, d)
+		if err != nil {
+			return fmt.Errorf("failed to write declaration %s: %w", d.entry.OutputPath, err)
+		}
+		written = append(written, outfile)
+	}
+	b.recordWritten(declarationOutputs, written)
+
+	return nil
+}
+
+// placeDeclaration rolls the declarations for an entry up into a single self-contained file at
+// its types output path, and returns the path of the file.
+func (b *Bundler) placeDeclaration(w io.Writer, tmpDir string, d declarationEntry) (string, error) {
+	outfile := filepath.Join(b.config.DistDir, utils.GetDistRelativePath(d.entry.OutputPath, b.config.DistDir))
+
+	entryPath := d.sourcePath.Input
+	roots := []string{b.config.SrcDir}
+
+	// Hand-written declarations are rolled up straight from the source directory
+	if !strings.HasPrefix(d.sourcePath.SrcExtension, ".d.") {
+		emitted, root, err := findEmittedDeclaration(tmpDir, b.config.SrcDir, d.sourcePath)
+		if err != nil {
+			return "", err
+		}
+		entryPath = emitted
+		roots = []string{root, b.config.SrcDir}
+	}
+
+	assignDefault := b.entryConfig(d.entry).CjsInterop && b.describesCommonJS(d.entry.OutputPath)
+	contents, warnings, err := rollupDeclarations(entryPath, roots, b.isExternalDeclarationImport, assignDefault)
+	if err != nil {
+		return "", err
+	}
+
+	for _, warning := range warnings {
+		printBuildWarnings(w, []api.Message{{Text: fmt.Sprintf("%s: %s", d.entry.OutputPath, warning)}})
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outfile), 0755); err != nil {
+		return "", err
+	}
+	return outfile, os.WriteFile(outfile, []byte(contents), 0644)
+}
+
+// isExternalDeclarationImport reports whether a package imported by the declarations will be
+// installed alongside the package, directly or through its @types package, so the import can be
+// kept as is.
+func (b *Bundler) isExternalDeclarationImport(specifier string) bool {
+	name := strings.TrimPrefix(specifier, "node:")
+	if name != specifier {
+		return true
+	}
+
+	parts := strings.SplitN(name, "/", 3)
+	name = parts[0]
+	if strings.HasPrefix(name, "@") && len(parts) > 1 {
+		name = parts[0] + "/" + parts[1]
+	}
+
+	// Types of @scope/pkg are published as @types/scope__pkg
+	typesName := "@types/" + strings.Replace(strings.TrimPrefix(name, "@"), "/", "__", 1)
+	for _, dependencies := range []map[string]string{b.pkg.Dependencies, b.pkg.PeerDependencies} {
+		if _, ok := dependencies[name]; ok {
+			return true
+		}
+		if _, ok := dependencies[typesName]; ok {
+			return true
+		}
+	}
+	for _, builtin := range nodeBuiltins {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// buildReferences builds the projects the tsconfig references, referenced projects first, as tsc
+// reads their declarations when compiling the project.
+func (b *Bundler) buildReferences(w io.Writer) error {
+	tsconfigPath := b.getTsconfigPath()
+	if tsconfigPath == "" {
+		return nil
+	}
+	references, err := utils.GetTsconfigReferences(tsconfigPath)
+	if err != nil {
+		return fmt.Errorf("error reading project references: %w", err)
+	}
+
+	for _, reference := range references {
+		if err := utils.RunTSCBuild(w, displayPath(reference)); err != nil {
+			var tscErr *utils.TSCError
+			if errors.As(err, &tscErr) {
+				printTSCDiagnostics(w, tscErr)
+			}
+			return fmt.Errorf("failed to build referenced project %s: %w", displayPath(reference), err)
+		}
+	}
+	return nil
+}
+
+func (b *Bundler) getTsconfigPath() string {
+	if b.config.TsconfigPath != "" {
+		return b.config.TsconfigPath
+	}
+	if utils.FileExists("tsconfig.json") {
+		return "tsconfig.json"
+	}
+	return ""
+}
+
+// emittedDeclarationRoot returns the directory in the tsc output that mirrors the source
+// directory, found through the declaration of the first entry.
+func (b *Bundler) emittedDeclarationRoot(tmpDir string, entries []declarationEntry) (string, error) {
+	for _, d := range entries {
+		if strings.HasPrefix(d.sourcePath.SrcExtension, ".d.") {
+			continue
+		}
+		_, root, err := findEmittedDeclaration(tmpDir, b.config.SrcDir, d.sourcePath)
+		return root, err
+	}
+	return "", nil
+}
+
+// findEmittedDeclaration locates the declaration tsc emitted for a source file. tsc mirrors the
+// layout below its rootDir, which is either the source directory or one of its parents, so the
+// source path is tried relative to the source directory first and the working directory second.
+// It returns the declaration path and the directory in tmpDir that corresponds to srcDir.
+func findEmittedDeclaration(tmpDir, srcDir string, sourcePath *utils.SourcePathResult) (string, string, error) {
+	declarationExtension, ok := declarationExtensions[sourcePath.SrcExtension]
+	if !ok {
+		return "", "", fmt.Errorf("no declaration can be generated from %s", sourcePath.Input)
+	}
+
+	relToSrc, err := filepath.Rel(srcDir, sourcePath.Input)
+	if err != nil {
+		return "", "", err
+	}
+	relToSrc = strings.TrimSuffix(relToSrc, sourcePath.SrcExtension) + declarationExtension
+
+	relToCwd := strings.TrimSuffix(filepath.Clean(sourcePath.Input), sourcePath.SrcExtension) + declarationExtension
+
+	for _, candidate := range []string{relToSrc, relToCwd} {
+		emitted := filepath.Join(tmpDir, candidate)
+		if utils.FileExists(emitted) {
+			root := strings.TrimSuffix(emitted, relToSrc)
+			return emitted, filepath.Clean(root), nil
+		}
+	}
+
+	// Fall back to searching for the declaration in case tsc used a different rootDir
+	var found string
+	err = filepath.WalkDir(tmpDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, string(filepath.Separator)+relToSrc) {
+			if found == "" || len(path) < len(found) {
+				found = path
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if found == "" {
+		return "", "", fmt.Errorf("tsc did not emit a declaration for %s", sourcePath.Input)
+	}
+
+	return found, filepath.Clean(strings.TrimSuffix(found, relToSrc)), nil
+}
+
+func printTSCDiagnostics(w io.Writer, err *utils.TSCError) {
+	for _, diagnostic := range err.Diagnostics {
+		colorAttr := color.FgRed
+		msgType := "Error"
+		if diagnostic.Severity == "warning" {
+			colorAttr = color.FgYellow
+			msgType = "Warning"
+		}
+
+		c := color.New(colorAttr).Add(color.Bold)
+		c.Fprintf(w, "%s: %s %s\n", msgType, diagnostic.Code, diagnostic.Message)
+		if diagnostic.File != "" {
+			fmt.Fprintf(w, "File: %s:%d:%d\n", diagnostic.File, diagnostic.Line, diagnostic.Column)
+		}
+		fmt.Fprintln(w)
+	}
+}
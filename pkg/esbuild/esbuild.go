@@ -1,18 +1,27 @@
 package esbuild
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/evanw/esbuild/pkg/api"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"squish/internal/config"
 	"squish/internal/utils"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/fatih/color"
 )
 
 type BundlerConfig struct {
+	// PackageDir is the directory of the package.json, where caches are kept
+	PackageDir       string
 	SrcDir           string
 	DistDir          string
 	Minify           bool
@@ -23,125 +32,726 @@ type BundlerConfig struct {
 	Sourcemap        string
 	CleanDist        bool
 	Bundle           bool
+	// Concurrency limits how many builds run at once, defaulting to the number of CPUs
+	Concurrency int
+	// Platform is used for entries whose export conditions do not imply one
+	Platform string
+	// BrowserBuiltins decides whether Node builtins fail browser builds or are stubbed
+	BrowserBuiltins string
+	// Overrides change the options of the entries they match
+	Overrides []config.Override
+	// Metafile writes the esbuild metafile of every entry next to its output
+	Metafile bool
+	// Budgets map output files or globs to the size they may not exceed, e.g. "12kb gz"
+	Budgets map[string]string
+	// Unbundled transpiles every source file reachable from the entries to its own output file
+	Unbundled bool
+	// CjsInterop makes the default export of CommonJS outputs their module.exports
+	CjsInterop bool
+	// CustomConditions are the custom export conditions the package.json exports use on purpose
+	CustomConditions []string
 }
 
 type Bundler struct {
 	config   *BundlerConfig
 	pkg      *config.PackageJSON
-	buildCtx *api.BuildContext
+	contexts map[string]*buildContext
+	mu       sync.Mutex
+	// canceled is set by Cancel until the next bundle starts
+	canceled atomic.Bool
+	// declarationsFailed is set when the last declaration build failed
+	declarationsFailed atomic.Bool
+	// transpiled holds the inputs of unbundled builds by their outputs, nil after a failed build
+	transpiled map[string]map[string]bool
+	// written holds the files each build wrote to dist by its outputs, for the size report
+	written map[string][]string
+	// measured holds the sizes of the output files reported after the last build
+	measured map[string]measuredFile
+	// entriesChecked is set once the entries of the configuration have been checked
+	entriesChecked bool
+}
+
+// ErrBuildCanceled is returned by Bundle when the bundle was canceled before it finished.
+var ErrBuildCanceled = errors.New("build canceled")
+
+// buildContext is a long-lived esbuild context for one build, reused for incremental rebuilds
+// for as long as the options it was created with stay the same.
+type buildContext struct {
+	ctx         api.BuildContext
+	fingerprint string
+	used        bool
+	// outputs lists the output files of the build
+	outputs string
+	// inputs are the absolute paths of the files the last successful build read, nil after a failed build
+	inputs map[string]bool
+	// metafile is the metafile of the last successful build, empty after a failed build
+	metafile string
 }
 
 func NewBundler(config *BundlerConfig, pkg *config.PackageJSON) *Bundler {
 	return &Bundler{
-		config: config,
-		pkg:    pkg,
+		config:     config,
+		pkg:        pkg,
+		contexts:   make(map[string]*buildContext),
+		transpiled: make(map[string]map[string]bool),
+		written:    make(map[string][]string),
+	}
+}
+
+// Config returns the configuration the bundler builds with.
+func (b *Bundler) Config() *BundlerConfig {
+	return b.config
+}
+
+// Reconfigure replaces the configuration and package.json the bundler builds with. The contexts
+// of previous builds are disposed, as changes to files like tsconfig.json do not show in their options.
+func (b *Bundler) Reconfigure(config *BundlerConfig, pkg *config.PackageJSON) {
+	b.Dispose()
+	b.config = config
+	b.pkg = pkg
+	b.entriesChecked = false
+}
+
+// TsconfigFiles returns the tsconfig used for building and every config it extends. Without a
+// tsconfig it returns the default tsconfig.json, which is used once it is created.
+func (b *Bundler) TsconfigFiles() ([]string, error) {
+	tsconfigPath := b.getTsconfigPath()
+	if tsconfigPath == "" {
+		return []string{"tsconfig.json"}, nil
 	}
+	return utils.GetTsconfigFiles(tsconfigPath)
+}
+
+// chunkNames places chunks shared between split ESM entries in a fixed directory under dist
+const chunkNames = "_chunks/[name]-[hash]"
+
+type resolvedEntry struct {
+	entry      config.ExportEntry
+	sourcePath *utils.SourcePathResult
 }
 
+// splitGroup is a set of ESM entries that are built together so shared modules become chunks.
+type splitGroup struct {
+	variant       string
+	distExtension string
+	entries       []resolvedEntry
+}
+
+// Bundle builds every entry of the package.
 func (b *Bundler) Bundle() error {
-	if b.config.CleanDist {
+	_, err := b.bundle(nil)
+	return err
+}
+
+// Rebuild builds the entries whose inputs, as recorded by their previous build, include one of the
+// changed files or directories. Entries without a successful previous build are always built. It
+// reports whether any entry was affected.
+func (b *Bundler) Rebuild(changed []string) (bool, error) {
+	changedPaths := make(map[string]bool, len(changed))
+	for _, path := range changed {
+		if abs, err := filepath.Abs(path); err == nil {
+			changedPaths[abs] = true
+		}
+	}
+	return b.bundle(changedPaths)
+}
+
+// bundle builds the entries affected by the changed paths, or every entry when changed is nil.
+func (b *Bundler) bundle(changed map[string]bool) (bool, error) {
+	b.canceled.Store(false)
+
+	if err := b.validateConfig(); err != nil {
+		return false, err
+	}
+
+	// Cleaning would remove the outputs of entries that are not rebuilt
+	if b.config.CleanDist && changed == nil {
 		if err := utils.CleanDirectory(b.config.DistDir); err != nil {
-			return fmt.Errorf("failed to clean dist directory: %w", err)
+			return false, fmt.Errorf("failed to clean dist directory: %w", err)
 		}
 	}
 
-	entries, err := b.pkg.GetExportEntries()
+	entries, err := b.getEntries()
 	if err != nil {
-		return err
+		return false, err
+	}
+	if err := b.checkEntries(entries); err != nil {
+		return false, err
 	}
 
-	// Generate TypeScript declaration files
-	//if err := utils.RunTSC(b.config.SrcDir, b.config.DistDir, b.config.TsconfigPath); err != nil {
-	//	return err
-	//}
+	typesEntries := []config.ExportEntry{}
+	cjsEntries := []resolvedEntry{}
+	groups := []*splitGroup{}
+	unbundledEntries := []resolvedEntry{}
 
 	for _, entry := range entries {
+		if entry.Type == config.PackageTypeTypes {
+			typesEntries = append(typesEntries, entry)
+			continue
+		}
+
 		sourcePath, err := utils.GetSourcePath(entry, b.config.SrcDir, b.config.DistDir)
 		if err != nil {
-			return fmt.Errorf("error resolving source path: %w", err)
+			return false, fmt.Errorf("error resolving source path: %w", err)
+		}
+
+		resolved := resolvedEntry{entry: entry, sourcePath: sourcePath}
+		if b.config.Unbundled {
+			unbundledEntries = append(unbundledEntries, resolved)
+			continue
+		}
+		if b.getFormat(entry.Type) != api.FormatESModule {
+			cjsEntries = append(cjsEntries, resolved)
+			continue
+		}
+
+		groups = addToSplitGroup(groups, resolved)
+	}
+
+	b.mu.Lock()
+	for _, c := range b.contexts {
+		c.used = false
+	}
+	b.mu.Unlock()
+
+	jobs := []buildJob{}
+	// builds are the outputs of every build of the package, skipped the ones not affected by the changes
+	builds := make(map[string]bool)
+	skipped := make(map[string]bool)
+	// addJob adds the build of the outputs when it is affected by the changes, logging the
+	// affected ones among them
+	addJob := func(outputs, affectedOutputs, reason string, affected bool, job buildJob) {
+		builds[outputs] = true
+		if changed == nil {
+			jobs = append(jobs, job)
+			return
+		}
+		if !affected {
+			skipped[outputs] = true
+			return
+		}
+		utils.Log("Rebuilding ", affectedOutputs, ": ", reason)
+		jobs = append(jobs, job)
+	}
+
+	transpileGroups := unbundledGroups(unbundledEntries)
+	if err := checkUnbundledGroups(transpileGroups); err != nil {
+		return false, err
+	}
+	for _, group := range transpileGroups {
+		group := group
+		outputs := group.outputs()
+		reason, affected := b.affectedBy(outputs, changed)
+		addJob(outputs, outputs, reason, affected, func(w io.Writer) error {
+			return b.transpileGroup(w, group)
+		})
+	}
+
+	for _, group := range groups {
+		group := group
+		outputs := group.outputs()
+		reason, affected := b.affectedBy(outputs, changed)
+		// Entries sharing chunks are built together, though only some of them may use the changes
+		addJob(outputs, b.affectedEntries(group, changed), reason, affected, func(w io.Writer) error {
+			return b.bundleSplitGroup(w, group)
+		})
+	}
+
+	for _, resolved := range cjsEntries {
+		resolved := resolved
+		reason, affected := b.affectedBy(resolved.entry.OutputPath, changed)
+		addJob(resolved.entry.OutputPath, resolved.entry.OutputPath, reason, affected, func(w io.Writer) error {
+			if err := b.bundleEntry(w, resolved.sourcePath, resolved.entry); err != nil {
+				return fmt.Errorf("failed to bundle entry: %s, %w", resolved.entry.OutputPath, err)
+			}
+			return nil
+		})
+	}
+
+	// Generate TypeScript declaration files
+	if len(typesEntries) > 0 {
+		reason, affected := b.declarationsAffectedBy(changed)
+		addJob(declarationOutputs, declarationOutputs, reason, affected, func(w io.Writer) error {
+			err := b.generateDeclarations(w, typesEntries)
+			b.declarationsFailed.Store(err != nil)
+			return err
+		})
+	}
+
+	if len(jobs) == 0 {
+		return false, nil
+	}
+
+	if err := b.runJobs(jobs); err != nil {
+		return true, err
+	}
+
+	b.disposeUnusedContexts(skipped)
+	b.forgetWritten(builds)
+
+	if err := b.reportSizes(os.Stdout); err != nil {
+		return true, err
+	}
+
+	return true, nil
+}
+
+// checkEntries checks the entries of the configuration once rather than on every rebuild, as its
+// warnings stay the same until the configuration changes.
+func (b *Bundler) checkEntries(entries []config.ExportEntry) error {
+	if b.entriesChecked {
+		return nil
+	}
+	if err := b.checkBrowserslist(entries); err != nil {
+		return err
+	}
+	b.warnUndeclaredConditions(entries)
+	b.entriesChecked = true
+	return nil
+}
+
+// warnUndeclaredConditions warns about export conditions that are neither known nor declared as
+// custom conditions, e.g. a misspelled "improt", which would otherwise silently become custom
+// conditions of the build.
+func (b *Bundler) warnUndeclaredConditions(entries []config.ExportEntry) {
+	warned := make(map[string]bool)
+	for _, entry := range entries {
+		entryConfig := b.entryConfig(entry)
+		for _, condition := range entry.Conditions {
+			if warned[condition] || config.IsKnownCondition(condition) || slices.Contains(entryConfig.CustomConditions, condition) || slices.Contains(entryConfig.ExportConditions, condition) {
+				continue
+			}
+			warned[condition] = true
+			utils.Log(fmt.Sprintf("Unknown export condition %q in %s, declare custom conditions with --custom-condition or customConditions", condition, entry.From))
 		}
+	}
+}
+
+func (b *Bundler) validateConfig() error {
+	if _, err := parsePlatform(b.config.Platform); err != nil {
+		return err
+	}
+
+	if _, _, err := parseTargets(b.config.Target); err != nil {
+		return err
+	}
 
-		if err := b.bundleEntry(sourcePath, entry); err != nil {
-			return fmt.Errorf("failed to bundle entry: %s, %w", entry.OutputPath, err)
+	for i, override := range b.config.Overrides {
+		if _, _, err := parseTargets(override.Target); err != nil {
+			return fmt.Errorf("overrides[%d]: %w", i, err)
 		}
 	}
 
+	if len(b.config.Target) == 0 {
+		if _, _, err := parseTargets([]string{b.pkg.GetNodeTarget()}); b.pkg.GetNodeTarget() != "" && err != nil {
+			return fmt.Errorf("engines.node: %w", err)
+		}
+	}
+
+	if _, err := config.ParseBudgets(b.config.Budgets); err != nil {
+		return err
+	}
+
+	if b.config.Unbundled && b.config.Metafile {
+		return fmt.Errorf("metafiles are not written in unbundled mode, disable either --metafile or --unbundled")
+	}
+
+	switch b.config.BrowserBuiltins {
+	case "", BrowserBuiltinsError, BrowserBuiltinsStub:
+	default:
+		return fmt.Errorf("invalid browser builtins mode %q, expected %q or %q", b.config.BrowserBuiltins, BrowserBuiltinsError, BrowserBuiltinsStub)
+	}
+
 	return nil
 }
 
-func (b *Bundler) bundleEntry(sourcePath *utils.SourcePathResult, entry config.ExportEntry) error {
-	outfile := filepath.Join(b.config.DistDir, entry.OutputPath)
+// addToSplitGroup adds an ESM entry to the group sharing its build variant and output extension,
+// since esbuild applies a single platform, set of conditions and output extension to a build.
+func addToSplitGroup(groups []*splitGroup, resolved resolvedEntry) []*splitGroup {
+	variant := entryVariant(resolved.entry)
+	for _, group := range groups {
+		if group.variant != variant || group.distExtension != resolved.sourcePath.DistExtension {
+			continue
+		}
 
-	// Ensure the output directory exists
-	if err := os.MkdirAll(filepath.Dir(outfile), 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		group.entries = append(group.entries, resolved)
+		return groups
 	}
 
-	//isEsm := b.getFormat(entry.Type) == api.FormatESModule
+	return append(groups, &splitGroup{
+		variant:       variant,
+		distExtension: resolved.sourcePath.DistExtension,
+		entries:       []resolvedEntry{resolved},
+	})
+}
 
-	plugins := []api.Plugin{
-		createEsbuildPlugin(CreateRequirePlugin()),
-		//createEsbuildPlugin(IsFormatEsmPlugin(isEsm)),
-		createEsbuildPlugin(ExternalizeNodeBuiltinsPlugin(b.config.Target)),
-		//createEsbuildPlugin(StripHashbangPlugin()),
+// outputs lists the output files of the group.
+func (g *splitGroup) outputs() string {
+	outputs := make([]string, 0, len(g.entries))
+	for _, resolved := range g.entries {
+		outputs = append(outputs, resolved.entry.OutputPath)
 	}
+	return strings.Join(outputs, ", ")
+}
 
-	if entry.IsExecutable {
-		plugins = append(plugins, createEsbuildPlugin(PatchBinaryPlugin([]string{entry.OutputPath})))
+// entryVariant describes the entry settings that change how its modules are compiled.
+func entryVariant(entry config.ExportEntry) string {
+	// Options are encoded as JSON, which sorts the environment variables
+	options, _ := json.Marshal(entry.Options)
+
+	conditions := append([]string{}, entry.Conditions...)
+	sort.Strings(conditions)
+
+	env := make([]string, 0, len(entry.Env))
+	for key, value := range entry.Env {
+		env = append(env, key+"="+value)
 	}
+	sort.Strings(env)
 
-	buildOptions := api.BuildOptions{
-		EntryPoints:       []string{sourcePath.Input},
-		Outfile:           outfile,
-		Bundle:            true,
-		Write:             true,
-		Format:            b.getFormat(entry.Type),
-		Target:            b.getEsbuildTarget(),
-		Platform:          api.PlatformNode,
-		External:          b.getExternalDependencies(),
-		Define:            b.getDefine(),
-		Sourcemap:         b.getSourcemap(),
-		MinifyWhitespace:  b.config.Minify,
-		MinifyIdentifiers: b.config.Minify,
-		MinifySyntax:      b.config.Minify,
-		Plugins:           plugins,
-		TreeShaking:       api.TreeShakingTrue,
+	return fmt.Sprintf("%s|%s|%s|%s", entry.Platform, strings.Join(conditions, ","), strings.Join(env, ","), options)
+}
+
+// getEntries returns the export entries of the package with subpath patterns expanded and
+// entries sharing an output file merged
+func (b *Bundler) getEntries() ([]config.ExportEntry, error) {
+	entries, err := b.pkg.GetExportEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err = utils.ExpandExportPatterns(entries, b.pkg.GetExcludedSubpaths(), b.config.SrcDir, b.config.DistDir)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx, err := api.Context(buildOptions)
+	entries, err = config.MergeExportEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.ApplyOverrides(entries, b.config.Overrides), nil
+}
+
+// entryConfig returns the configuration with the options of the overrides matching the entry applied.
+func (b *Bundler) entryConfig(entry config.ExportEntry) *BundlerConfig {
+	entryConfig := *b.config
+	options := entry.Options
+	if options.Minify != nil {
+		entryConfig.Minify = *options.Minify
+	}
+	if options.Target != nil {
+		entryConfig.Target = options.Target
+	}
+	if options.ExportConditions != nil {
+		entryConfig.ExportConditions = options.ExportConditions
+	}
+	if options.Sourcemap != nil {
+		entryConfig.Sourcemap = *options.Sourcemap
+	}
+	if options.BrowserBuiltins != nil {
+		entryConfig.BrowserBuiltins = *options.BrowserBuiltins
+	}
+	if options.CjsInterop != nil {
+		entryConfig.CjsInterop = *options.CjsInterop
+	}
+	return &entryConfig
+}
+
+// bundleSplitGroup builds all ESM entries of a group in a single esbuild invocation with code
+// splitting, so modules shared between entries are emitted once as chunks.
+func (b *Bundler) bundleSplitGroup(w io.Writer, group *splitGroup) error {
+	entryPoints := make([]api.EntryPoint, 0, len(group.entries))
+	executables := []string{}
+
+	for _, resolved := range group.entries {
+		distPath := utils.GetDistRelativePath(resolved.entry.OutputPath, b.config.DistDir)
+		entryPoints = append(entryPoints, api.EntryPoint{
+			InputPath:  resolved.sourcePath.Input,
+			OutputPath: strings.TrimSuffix(distPath, resolved.sourcePath.DistExtension),
+		})
+		if resolved.entry.IsExecutable {
+			executables = append(executables, resolved.entry.OutputPath)
+		}
+	}
+
+	buildOptions := b.getBuildOptions(group.entries[0].entry, executables)
+	buildOptions.EntryPointsAdvanced = entryPoints
+	buildOptions.Outdir = b.config.DistDir
+	buildOptions.Splitting = true
+	buildOptions.ChunkNames = chunkNames
+	if group.distExtension != ".js" {
+		buildOptions.OutExtension = map[string]string{".js": group.distExtension}
+	}
+
+	result, err := b.build(w, group.outputs(), buildOptions)
 	if err != nil {
 		return err
 	}
 
-	if b.config.TsconfigPath != "" {
-		buildOptions.Tsconfig = b.config.TsconfigPath
+	if len(result.Errors) > 0 {
+		printBuildErrors(w, result.Errors)
+		return fmt.Errorf("build failed for %s", group.outputs())
 	}
 
-	if len(b.config.ExportConditions) > 0 {
-		buildOptions.Conditions = b.config.ExportConditions
+	if len(result.Warnings) > 0 {
+		printBuildWarnings(w, result.Warnings)
 	}
+	b.recordWritten(group.outputs(), metafileOutputs(result.Metafile))
 
-	var result api.BuildResult
+	if b.config.Metafile {
+		outputPaths := make([]string, 0, len(group.entries))
+		for _, resolved := range group.entries {
+			outputPaths = append(outputPaths, filepath.Join(b.config.DistDir, utils.GetDistRelativePath(resolved.entry.OutputPath, b.config.DistDir)))
+		}
+		return writeMetafiles(result.Metafile, outputPaths)
+	}
 
-	if b.buildCtx != nil {
-		result = ctx.Rebuild()
-	} else {
-		result = api.Build(buildOptions)
-		b.buildCtx = &ctx
+	return nil
+}
+
+func (b *Bundler) bundleEntry(w io.Writer, sourcePath *utils.SourcePathResult, entry config.ExportEntry) error {
+	outfile := filepath.Join(b.config.DistDir, utils.GetDistRelativePath(entry.OutputPath, b.config.DistDir))
+
+	// Ensure the output directory exists
+	if err := os.MkdirAll(filepath.Dir(outfile), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	executables := []string{}
+	if entry.IsExecutable {
+		executables = append(executables, entry.OutputPath)
+	}
+
+	buildOptions := b.getBuildOptions(entry, executables)
+	buildOptions.EntryPoints = []string{sourcePath.Input}
+	buildOptions.Outfile = outfile
+
+	result, err := b.build(w, entry.OutputPath, buildOptions)
+	if err != nil {
+		return err
 	}
 
 	if len(result.Errors) > 0 {
-		printBuildErrors(result.Errors)
+		printBuildErrors(w, result.Errors)
 		return fmt.Errorf("build failed for %s", entry.OutputPath)
 	}
 
 	if len(result.Warnings) > 0 {
-		printBuildWarnings(result.Warnings)
+		printBuildWarnings(w, result.Warnings)
+	}
+	b.recordWritten(entry.OutputPath, metafileOutputs(result.Metafile))
+
+	if b.config.Metafile {
+		return writeMetafiles(result.Metafile, []string{outfile})
 	}
 
 	return nil
 }
 
+// build runs the build of the outputs, reusing the context of their previous build so unchanged
+// modules are not parsed again. The context is recreated when the options change.
+func (b *Bundler) build(w io.Writer, outputs string, buildOptions api.BuildOptions) (api.BuildResult, error) {
+	key := contextKey(outputs, buildOptions)
+	fingerprint := optionsFingerprint(buildOptions)
+
+	b.mu.Lock()
+	c, ok := b.contexts[key]
+	if ok && c.fingerprint != fingerprint {
+		c.ctx.Dispose()
+		delete(b.contexts, key)
+		ok = false
+	}
+	if ok {
+		c.used = true
+	}
+	b.mu.Unlock()
+
+	if !ok {
+		ctx, ctxErr := api.Context(buildOptions)
+		if ctxErr != nil {
+			printBuildErrors(w, ctxErr.Errors)
+			return api.BuildResult{}, fmt.Errorf("invalid build options for %s", key)
+		}
+
+		c = &buildContext{ctx: ctx, fingerprint: fingerprint, used: true, outputs: outputs}
+		b.mu.Lock()
+		b.contexts[key] = c
+		b.mu.Unlock()
+	}
+
+	if b.canceled.Load() {
+		return api.BuildResult{}, ErrBuildCanceled
+	}
+	result := c.ctx.Rebuild()
+	// A canceled rebuild reports the cancellation as an error, which is not a problem of the build
+	if b.canceled.Load() {
+		return api.BuildResult{}, ErrBuildCanceled
+	}
+
+	inputs, metafile := map[string]bool(nil), ""
+	if len(result.Errors) == 0 {
+		inputs, metafile = metafileInputs(result.Metafile), result.Metafile
+	}
+	b.mu.Lock()
+	c.inputs, c.metafile = inputs, metafile
+	b.mu.Unlock()
+
+	return result, nil
+}
+
+// Cancel stops the running bundle: builds in progress are canceled where esbuild allows it and
+// no further builds are started. The running Bundle returns ErrBuildCanceled.
+func (b *Bundler) Cancel() {
+	b.canceled.Store(true)
+
+	b.mu.Lock()
+	contexts := make([]api.BuildContext, 0, len(b.contexts))
+	for _, c := range b.contexts {
+		contexts = append(contexts, c.ctx)
+	}
+	b.mu.Unlock()
+
+	// Cancel waits for the build to stop, so it is called without holding the lock
+	for _, ctx := range contexts {
+		ctx.Cancel()
+	}
+}
+
+// Dispose releases the esbuild contexts kept for incremental rebuilds.
+func (b *Bundler) Dispose() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, c := range b.contexts {
+		c.ctx.Dispose()
+		delete(b.contexts, key)
+	}
+	b.transpiled = make(map[string]map[string]bool)
+}
+
+// recordWritten keeps the files a build wrote to dist, replacing the ones of its previous build.
+func (b *Bundler) recordWritten(outputs string, files []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.written[outputs] = files
+}
+
+// forgetWritten drops the written files of builds that no longer exist, e.g. removed entries.
+func (b *Bundler) forgetWritten(builds map[string]bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for outputs := range b.written {
+		if !builds[outputs] {
+			delete(b.written, outputs)
+		}
+	}
+}
+
+// disposeUnusedContexts releases contexts of builds that no longer exist, e.g. removed entries.
+// Contexts of builds skipped because they were not affected by a change are kept.
+func (b *Bundler) disposeUnusedContexts(skipped map[string]bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, c := range b.contexts {
+		if !c.used && !skipped[c.outputs] {
+			c.ctx.Dispose()
+			delete(b.contexts, key)
+		}
+	}
+}
+
+// contextKey identifies a build by its outputs, format and platform.
+func contextKey(outputs string, buildOptions api.BuildOptions) string {
+	return fmt.Sprintf("%s|%d|%d", outputs, buildOptions.Format, buildOptions.Platform)
+}
+
+// optionsFingerprint describes build options so changes can be detected. Plugins hold functions
+// and are compared by name.
+func optionsFingerprint(buildOptions api.BuildOptions) string {
+	pluginNames := make([]string, 0, len(buildOptions.Plugins))
+	for _, plugin := range buildOptions.Plugins {
+		pluginNames = append(pluginNames, plugin.Name)
+	}
+	buildOptions.Plugins = nil
+	return fmt.Sprintf("%#v|%v", buildOptions, pluginNames)
+}
+
+// getBuildOptions returns the options shared by every build of the entry's format and platform.
+// Callers fill in the entry points and output location.
+func (b *Bundler) getBuildOptions(entry config.ExportEntry, executables []string) api.BuildOptions {
+	entryConfig := b.entryConfig(entry)
+	platform := b.getPlatform(entry)
+	format := b.getFormat(entry.Type)
+	define := b.getDefine(entry)
+
+	plugins := []api.Plugin{
+		//createEsbuildPlugin(StripHashbangPlugin()),
+	}
+
+	// Shims make CommonJS globals and import.meta work in both formats when running in Node
+	var inject []string
+	if platform == api.PlatformNode {
+		var shimDefines map[string]string
+		inject, shimDefines = interopShims(format)
+		for key, value := range shimDefines {
+			define[key] = value
+		}
+		plugins = append(plugins, createEsbuildPlugin(InteropShimsPlugin(format)))
+	}
+
+	if platform == api.PlatformBrowser {
+		plugins = append(plugins, createEsbuildPlugin(BrowserNodeBuiltinsPlugin(entryConfig.BrowserBuiltins, b.pkg.Browser.Modules())))
+	} else {
+		plugins = append(plugins, createEsbuildPlugin(ExternalizeNodeBuiltinsPlugin(b.getTargets(entry))))
+	}
+
+	if len(executables) > 0 {
+		plugins = append(plugins, createEsbuildPlugin(PatchBinaryPlugin(executables)))
+	}
+
+	target, engines := b.getEsbuildTarget(entry)
+
+	buildOptions := api.BuildOptions{
+		Bundle:            true,
+		Write:             true,
+		Format:            format,
+		Target:            target,
+		Engines:           engines,
+		Platform:          platform,
+		External:          b.getExternalDependencies(),
+		Define:            define,
+		Inject:            inject,
+		Sourcemap:         getSourcemap(entryConfig.Sourcemap),
+		MinifyWhitespace:  entryConfig.Minify,
+		MinifyIdentifiers: entryConfig.Minify,
+		MinifySyntax:      entryConfig.Minify,
+		Plugins:           plugins,
+		TreeShaking:       api.TreeShakingTrue,
+		Metafile:          true,
+	}
+
+	if format == api.FormatCommonJS && entryConfig.CjsInterop {
+		buildOptions.Footer = map[string]string{"js": getCjsInteropFooter(entryConfig.Minify)}
+	}
+
+	// esbuild has no main fields for neutral builds, which breaks dependencies with only a main field
+	if platform == api.PlatformNeutral {
+		buildOptions.MainFields = []string{"module", "main"}
+	}
+
+	if b.config.TsconfigPath != "" {
+		buildOptions.Tsconfig = b.config.TsconfigPath
+	}
+
+	conditions := append(append([]string{}, entryConfig.ExportConditions...), entry.Conditions...)
+	if len(conditions) > 0 {
+		buildOptions.Conditions = conditions
+	}
+
+	return buildOptions
+}
+
 func (b *Bundler) getFormat(packageType config.PackageType) api.Format {
 	switch packageType {
 	case config.PackageTypeModule:
@@ -153,26 +763,46 @@ func (b *Bundler) getFormat(packageType config.PackageType) api.Format {
 	}
 }
 
-func (b *Bundler) getEsbuildTarget() api.Target {
-	targets := make([]api.Target, 0, len(b.config.Target))
-	for _, t := range b.config.Target {
-		switch t {
-		case "es2022":
-			targets = append(targets, api.ES2022)
-		case "es2021":
-			targets = append(targets, api.ES2021)
-		case "es2020":
-			targets = append(targets, api.ES2020)
-		// Add more cases as needed
-		default:
-			targets = append(targets, api.ES2022) // Default to ES2022
-		}
+// getPlatform returns the platform an entry is built for: the platform of a matching override,
+// the one implied by its export conditions, or the configured default.
+func (b *Bundler) getPlatform(entry config.ExportEntry) api.Platform {
+	platform := entry.Platform
+	if entry.Options.Platform != nil {
+		platform = *entry.Options.Platform
+	}
+	if platform == "" {
+		platform = b.config.Platform
+	}
+	p, _ := parsePlatform(platform)
+	return p
+}
+
+func platformName(platform api.Platform) string {
+	switch platform {
+	case api.PlatformBrowser:
+		return "browser"
+	case api.PlatformNeutral:
+		return "neutral"
+	default:
+		return "node"
 	}
-	return targets[0] // esbuild only accepts a single target, so we use the first one
 }
 
-func (b *Bundler) getSourcemap() api.SourceMap {
-	switch b.config.Sourcemap {
+func parsePlatform(platform string) (api.Platform, error) {
+	switch platform {
+	case "", "node":
+		return api.PlatformNode, nil
+	case "browser":
+		return api.PlatformBrowser, nil
+	case "neutral":
+		return api.PlatformNeutral, nil
+	default:
+		return api.PlatformNode, fmt.Errorf("invalid platform %q, expected node, browser or neutral", platform)
+	}
+}
+
+func getSourcemap(sourcemap string) api.SourceMap {
+	switch sourcemap {
 	case "inline":
 		return api.SourceMapInline
 	case "":
@@ -182,14 +812,27 @@ func (b *Bundler) getSourcemap() api.SourceMap {
 	}
 }
 
-func (b *Bundler) getDefine() map[string]string {
+func (b *Bundler) getDefine(entry config.ExportEntry) map[string]string {
 	define := make(map[string]string)
-	for key, value := range b.config.Env {
+	for key, value := range b.getEnv(entry) {
 		define[fmt.Sprintf("process.env.%s", key)] = fmt.Sprintf("\"%s\"", value)
 	}
 	return define
 }
 
+// getEnv returns the compile-time environment variables of an entry. Variables implied by its
+// export conditions, e.g. NODE_ENV for "production", replace configured ones, and variables of
+// matching overrides replace both.
+func (b *Bundler) getEnv(entry config.ExportEntry) map[string]string {
+	env := make(map[string]string)
+	for _, source := range []map[string]string{b.config.Env, entry.Env, entry.Options.Env} {
+		for key, value := range source {
+			env[key] = value
+		}
+	}
+	return env
+}
+
 func (b *Bundler) getExternalDependencies() []string {
 	externals := make([]string, 0)
 	if !b.config.Bundle {
@@ -203,6 +846,8 @@ func (b *Bundler) getExternalDependencies() []string {
 	for dep := range b.pkg.DevDependencies {
 		externals = append(externals, dep)
 	}
+	// Keep the order stable so unchanged options are recognised between rebuilds
+	sort.Strings(externals)
 	return externals
 }
 
@@ -229,26 +874,29 @@ func createEsbuildPlugin(p Plugin) api.Plugin {
 	}
 }
 
-func printBuildErrors(errors []api.Message) {
+func printBuildErrors(w io.Writer, errors []api.Message) {
 	for _, err := range errors {
-		printBuildMessage("Error", err, color.FgRed)
+		printBuildMessage(w, "Error", err, color.FgRed)
 	}
 }
 
-func printBuildWarnings(warnings []api.Message) {
+func printBuildWarnings(w io.Writer, warnings []api.Message) {
 	for _, warning := range warnings {
-		printBuildMessage("Warning", warning, color.FgYellow)
+		printBuildMessage(w, "Warning", warning, color.FgYellow)
 	}
 }
 
-func printBuildMessage(msgType string, msg api.Message, colorAttr color.Attribute) {
+func printBuildMessage(w io.Writer, msgType string, msg api.Message, colorAttr color.Attribute) {
 	c := color.New(colorAttr).Add(color.Bold)
-	c.Printf("%s: %s\n", msgType, msg.Text)
+	c.Fprintf(w, "%s: %s\n", msgType, msg.Text)
 	if msg.Location != nil {
-		fmt.Printf("File: %s:%d:%d\n", msg.Location.File, msg.Location.Line, msg.Location.Column)
-		fmt.Println(msg.Location.LineText)
+		fmt.Fprintf(w, "File: %s:%d:%d\n", msg.Location.File, msg.Location.Line, msg.Location.Column)
+		fmt.Fprintln(w, msg.Location.LineText)
 		underline := strings.Repeat(" ", msg.Location.Column) + strings.Repeat("^", msg.Location.Length)
-		fmt.Println(underline)
+		fmt.Fprintln(w, underline)
+	}
+	for _, note := range msg.Notes {
+		fmt.Fprintf(w, "Note: %s\n", note.Text)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 }
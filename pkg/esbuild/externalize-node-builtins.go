@@ -7,8 +7,10 @@ import (
 )
 
 var nodeBuiltins = []string{
-	"assert", "buffer", "child_process", "cluster", "crypto", "dgram", "dns", "domain", "events", "fs", "http", "https", "net",
-	"os", "path", "punycode", "querystring", "readline", "stream", "string_decoder", "tls", "tty", "url", "util", "v8", "vm", "zlib",
+	"assert", "async_hooks", "buffer", "child_process", "cluster", "console", "constants", "crypto", "dgram",
+	"diagnostics_channel", "dns", "domain", "events", "fs", "http", "http2", "https", "inspector", "module", "net",
+	"os", "path", "perf_hooks", "process", "punycode", "querystring", "readline", "repl", "stream", "string_decoder",
+	"sys", "timers", "tls", "trace_events", "tty", "url", "util", "v8", "vm", "wasi", "worker_threads", "zlib",
 }
 
 func ExternalizeNodeBuiltinsPlugin(target []string) Plugin {
@@ -27,10 +29,8 @@ func ExternalizeNodeBuiltinsPlugin(target []string) Plugin {
 					return api.OnResolveResult{External: true}, nil
 				}
 
-				for _, builtin := range nodeBuiltins {
-					if args.Path == builtin {
-						return api.OnResolveResult{External: true}, nil
-					}
+				if isNodeBuiltin(args.Path) {
+					return api.OnResolveResult{External: true}, nil
 				}
 
 				return api.OnResolveResult{}, nil
@@ -45,7 +45,10 @@ func shouldStripNodeProtocol(target []string) bool {
 			version := strings.TrimPrefix(t, "node")
 			parts := strings.Split(version, ".")
 			major, _ := strconv.Atoi(parts[0])
-			minor, _ := strconv.Atoi(parts[1])
+			minor := 0
+			if len(parts) > 1 {
+				minor, _ = strconv.Atoi(parts[1])
+			}
 
 			if (major == 12 && minor >= 20) || major >= 14 {
 				return false
@@ -0,0 +1,227 @@
+package esbuild
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/evanw/esbuild/pkg/api"
+	"os"
+	"strings"
+)
+
+// interopShimsNamespace is the namespace of the virtual modules the CommonJS shims are loaded from
+const interopShimsNamespace = "squish-interop-shims"
+
+// cjsShims define the import.meta properties in CommonJS builds that have no CommonJS counterpart.
+// Each shim is a module of its own, so esbuild only includes the ones a build uses.
+var cjsShims = map[string]string{
+	"squish:shims/import.meta.url": `export const __squish_import_meta_url = require("node:url").pathToFileURL(__filename).href;`,
+}
+
+// cjsShimDefines replace import.meta properties in CommonJS builds, which esbuild leaves empty
+var cjsShimDefines = map[string]string{
+	"import.meta.url":      "__squish_import_meta_url",
+	"import.meta.dirname":  "__dirname",
+	"import.meta.filename": "__filename",
+}
+
+// esmShimDefines rename the CommonJS globals in ES module builds, so the outputs using them can be
+// found and shimmed. Shims are added to the outputs rather than injected, as esbuild moves
+// injected modules into a shared chunk when splitting, where import.meta.url is the chunk's.
+var esmShimDefines = map[string]string{
+	"__filename": "__squish_filename",
+	"__dirname":  "__squish_dirname",
+}
+
+// dynamicRequireHelper is part of the helper esbuild adds to ES modules that call require, which
+// only works where a require function is defined
+var dynamicRequireHelper = []byte(`Dynamic require of "`)
+
+// inlineSourceMapPrefix starts the comment of an inline source map
+const inlineSourceMapPrefix = "//# sourceMappingURL=data:application/json;base64,"
+
+// interopShims returns the modules injected into builds of a format, and the defines that
+// reference the shims.
+func interopShims(format api.Format) ([]string, map[string]string) {
+	if format != api.FormatCommonJS {
+		return nil, esmShimDefines
+	}
+
+	inject := make([]string, 0, len(cjsShims))
+	for path := range cjsShims {
+		inject = append(inject, path)
+	}
+	return inject, cjsShimDefines
+}
+
+// InteropShimsPlugin lets the same source run as both formats in Node. CommonJS builds get the
+// injected import.meta shims, and ES module outputs get require, __filename and __dirname
+// defined through createRequire and import.meta.url when they use them.
+func InteropShimsPlugin(format api.Format) Plugin {
+	return NewPluginBuilder("interop-shims").
+		Setup(func(build api.PluginBuild) {
+			if format == api.FormatCommonJS {
+				build.OnResolve(api.OnResolveOptions{Filter: "^squish:shims/"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					if _, ok := cjsShims[args.Path]; !ok {
+						return api.OnResolveResult{}, nil
+					}
+					return api.OnResolveResult{
+						Path:        args.Path,
+						Namespace:   interopShimsNamespace,
+						SideEffects: api.SideEffectsFalse,
+					}, nil
+				})
+
+				build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: interopShimsNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					contents := cjsShims[args.Path]
+					return api.OnLoadResult{
+						Contents: &contents,
+						Loader:   api.LoaderJS,
+					}, nil
+				})
+				return
+			}
+
+			write := build.InitialOptions.Write
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				for i, outputFile := range result.OutputFiles {
+					if strings.HasSuffix(outputFile.Path, ".map") {
+						continue
+					}
+					shim := esmShim(outputFile.Contents)
+					if shim == "" {
+						continue
+					}
+
+					contents, line := insertShim(outputFile.Contents, shim)
+					contents, err := shiftInlineSourceMap(contents, line)
+					if err != nil {
+						return api.OnEndResult{}, fmt.Errorf("failed to update the source map of %s: %w", outputFile.Path, err)
+					}
+					result.OutputFiles[i].Contents = contents
+					if write {
+						if err := os.WriteFile(outputFile.Path, contents, 0644); err != nil {
+							return api.OnEndResult{}, err
+						}
+					}
+
+					for j, mapFile := range result.OutputFiles {
+						if mapFile.Path != outputFile.Path+".map" {
+							continue
+						}
+						shifted, err := insertSourceMapLine(mapFile.Contents, line)
+						if err != nil {
+							return api.OnEndResult{}, fmt.Errorf("failed to update %s: %w", mapFile.Path, err)
+						}
+						result.OutputFiles[j].Contents = shifted
+						if write {
+							if err := os.WriteFile(mapFile.Path, shifted, 0644); err != nil {
+								return api.OnEndResult{}, err
+							}
+						}
+					}
+				}
+				return api.OnEndResult{}, nil
+			})
+		}).
+		Build()
+}
+
+// esmShim returns the line defining the CommonJS globals an ES module output uses, empty when it
+// uses none. It is a single line so source maps only have to be shifted by a line.
+func esmShim(contents []byte) string {
+	usesRequire := bytes.Contains(contents, dynamicRequireHelper)
+	usesDirname := bytes.Contains(contents, []byte("__squish_dirname"))
+	usesFilename := usesDirname || bytes.Contains(contents, []byte("__squish_filename"))
+
+	statements := []string{}
+	if usesRequire {
+		statements = append(statements, `import { createRequire as __squish_createRequire } from "module";`)
+	}
+	if usesFilename {
+		statements = append(statements, `import { fileURLToPath as __squish_fileURLToPath } from "url";`)
+	}
+	if usesDirname {
+		statements = append(statements, `import { dirname as __squish_pathDirname } from "path";`)
+	}
+	if usesRequire {
+		statements = append(statements, `const require = __squish_createRequire(import.meta.url);`)
+	}
+	if usesFilename {
+		statements = append(statements, `const __squish_filename = __squish_fileURLToPath(import.meta.url);`)
+	}
+	if usesDirname {
+		statements = append(statements, `const __squish_dirname = __squish_pathDirname(__squish_filename);`)
+	}
+	return strings.Join(statements, " ")
+}
+
+// insertShim adds a shim as the first line of an output, after its hashbang, and returns the line
+// it was inserted at.
+func insertShim(contents []byte, shim string) ([]byte, int) {
+	at, line := 0, 0
+	if bytes.HasPrefix(contents, []byte("#!")) {
+		if end := bytes.IndexByte(contents, '\n'); end >= 0 {
+			at, line = end+1, 1
+		}
+	}
+
+	result := make([]byte, 0, len(contents)+len(shim)+1)
+	result = append(result, contents[:at]...)
+	result = append(result, shim+"\n"...)
+	result = append(result, contents[at:]...)
+	return result, line
+}
+
+// shiftInlineSourceMap inserts a generated line into the inline source map of an output, if it has one.
+func shiftInlineSourceMap(contents []byte, line int) ([]byte, error) {
+	start := bytes.LastIndex(contents, []byte(inlineSourceMapPrefix))
+	if start < 0 {
+		return contents, nil
+	}
+	dataStart := start + len(inlineSourceMapPrefix)
+	dataEnd := dataStart
+	for dataEnd < len(contents) && contents[dataEnd] != '\n' && contents[dataEnd] != '\r' {
+		dataEnd++
+	}
+
+	sourceMap, err := base64.StdEncoding.DecodeString(string(contents[dataStart:dataEnd]))
+	if err != nil {
+		return nil, err
+	}
+	shifted, err := insertSourceMapLine(sourceMap, line)
+	if err != nil {
+		return nil, err
+	}
+
+	result := append([]byte{}, contents[:dataStart]...)
+	result = append(result, base64.StdEncoding.EncodeToString(shifted)...)
+	return append(result, contents[dataEnd:]...), nil
+}
+
+// insertSourceMapLine inserts an empty generated line into the mappings of a source map, so the
+// mappings of the lines after it stay correct.
+func insertSourceMapLine(sourceMap []byte, line int) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(sourceMap, &fields); err != nil {
+		return nil, err
+	}
+	var mappings string
+	if err := json.Unmarshal(fields["mappings"], &mappings); err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(mappings, ";")
+	if line > len(lines) {
+		line = len(lines)
+	}
+	lines = append(lines[:line], append([]string{""}, lines[line:]...)...)
+
+	encoded, err := json.Marshal(strings.Join(lines, ";"))
+	if err != nil {
+		return nil, err
+	}
+	fields["mappings"] = encoded
+	return json.Marshal(fields)
+}
@@ -0,0 +1,131 @@
+package esbuild
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// metafileSuffix is appended to an output path to get the path its metafile is written to
+const metafileSuffix = ".meta.json"
+
+// MetafilePath returns the path the metafile of an entry's output is written to with --metafile.
+func MetafilePath(outputPath string) string {
+	return outputPath + metafileSuffix
+}
+
+// rawMetafile keeps the inputs and outputs of an esbuild metafile as they are, so filtered
+// metafiles contain everything esbuild reported
+type rawMetafile struct {
+	Inputs  map[string]json.RawMessage `json:"inputs"`
+	Outputs map[string]json.RawMessage `json:"outputs"`
+}
+
+type metafileOutput struct {
+	Imports []struct {
+		Path     string `json:"path"`
+		External bool   `json:"external"`
+	} `json:"imports"`
+	Inputs map[string]json.RawMessage `json:"inputs"`
+}
+
+// writeMetafiles writes the metafile of a build for each of its entry outputs, limited to the
+// output, the chunks it loads and the inputs they contain.
+func writeMetafiles(metafile string, outputPaths []string) error {
+	var meta rawMetafile
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return fmt.Errorf("failed to read metafile: %w", err)
+	}
+
+	for _, outputPath := range outputPaths {
+		data, err := json.MarshalIndent(entryMetafile(meta, outputPath), "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(MetafilePath(outputPath), data, 0644); err != nil {
+			return fmt.Errorf("failed to write metafile: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// metafileOutputs returns the absolute paths of the files in the outputs of an esbuild metafile.
+func metafileOutputs(metafile string) []string {
+	var meta rawMetafile
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil
+	}
+
+	outputs := make([]string, 0, len(meta.Outputs))
+	for _, output := range sortedKeys(meta.Outputs) {
+		if abs, err := filepath.Abs(output); err == nil {
+			outputs = append(outputs, abs)
+		}
+	}
+	return outputs
+}
+
+// entryMetafile filters a metafile down to an entry output and the chunks it imports, directly
+// or through other chunks.
+func entryMetafile(meta rawMetafile, outputPath string) rawMetafile {
+	filtered := rawMetafile{
+		Inputs:  make(map[string]json.RawMessage),
+		Outputs: make(map[string]json.RawMessage),
+	}
+
+	// Metafile paths are relative to the working directory
+	if filepath.IsAbs(outputPath) {
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, outputPath); err == nil {
+				outputPath = rel
+			}
+		}
+	}
+
+	queue := []string{filepath.ToSlash(filepath.Clean(outputPath))}
+	for len(queue) > 0 {
+		key := queue[0]
+		queue = queue[1:]
+
+		raw, ok := meta.Outputs[key]
+		if !ok {
+			continue
+		}
+		if _, seen := filtered.Outputs[key]; seen {
+			continue
+		}
+		filtered.Outputs[key] = raw
+		if sourcemap, ok := meta.Outputs[key+".map"]; ok {
+			filtered.Outputs[key+".map"] = sourcemap
+		}
+
+		var output metafileOutput
+		if err := json.Unmarshal(raw, &output); err != nil {
+			continue
+		}
+		for _, imported := range output.Imports {
+			if !imported.External {
+				queue = append(queue, imported.Path)
+			}
+		}
+		for _, input := range sortedKeys(output.Inputs) {
+			if raw, ok := meta.Inputs[input]; ok {
+				filtered.Inputs[input] = raw
+			}
+		}
+	}
+
+	return filtered
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
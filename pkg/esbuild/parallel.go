@@ -0,0 +1,55 @@
+package esbuild
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// buildJob is an independent unit of work of a bundle, such as one esbuild invocation. It writes
+// its log output to w so output of concurrent jobs does not interleave.
+type buildJob func(w io.Writer) error
+
+// runJobs runs jobs on a bounded worker pool. Output is printed in job order once all jobs have
+// finished, and the errors of every failed job are returned together. Once the bundle is canceled
+// no further jobs are started and ErrBuildCanceled is returned without printing any output.
+func (b *Bundler) runJobs(jobs []buildJob) error {
+	limit := b.config.Concurrency
+	if limit <= 0 {
+		limit = runtime.NumCPU()
+	}
+
+	outputs := make([]bytes.Buffer, len(jobs))
+	errs := make([]error, len(jobs))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i, job := range jobs {
+		sem <- struct{}{}
+		if b.canceled.Load() {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int, job buildJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = job(&outputs[i])
+		}(i, job)
+	}
+
+	wg.Wait()
+
+	if b.canceled.Load() {
+		return ErrBuildCanceled
+	}
+
+	for i := range outputs {
+		os.Stdout.Write(outputs[i].Bytes())
+	}
+
+	return errors.Join(errs...)
+}
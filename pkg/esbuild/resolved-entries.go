@@ -0,0 +1,62 @@
+package esbuild
+
+import (
+	"squish/internal/config"
+)
+
+// ResolvedEntry describes how an entry is built once the configuration file, its overrides,
+// command line flags and package.json inference have been applied.
+type ResolvedEntry struct {
+	Output     string             `json:"output"`
+	Subpath    string             `json:"subpath,omitempty"`
+	From       string             `json:"from"`
+	Format     config.PackageType `json:"format"`
+	Platform   string             `json:"platform,omitempty"`
+	Target     []string           `json:"target,omitempty"`
+	Minify     bool               `json:"minify"`
+	Sourcemap  string             `json:"sourcemap,omitempty"`
+	Env        map[string]string  `json:"env,omitempty"`
+	Conditions []string           `json:"conditions,omitempty"`
+	Executable bool               `json:"executable,omitempty"`
+}
+
+// ResolveEntries returns the entries of the package with the options each is built with.
+func (b *Bundler) ResolveEntries() ([]ResolvedEntry, error) {
+	if err := b.validateConfig(); err != nil {
+		return nil, err
+	}
+
+	entries, err := b.getEntries()
+	if err != nil {
+		return nil, err
+	}
+	if err := b.checkEntries(entries); err != nil {
+		return nil, err
+	}
+
+	resolved := make([]ResolvedEntry, 0, len(entries))
+	for _, entry := range entries {
+		r := ResolvedEntry{
+			Output:     entry.OutputPath,
+			Subpath:    entry.Subpath,
+			From:       entry.From,
+			Format:     entry.Type,
+			Executable: entry.IsExecutable,
+		}
+
+		// Declarations are emitted by tsc, so none of the bundling options apply to them
+		if entry.Type != config.PackageTypeTypes {
+			entryConfig := b.entryConfig(entry)
+			r.Platform = platformName(b.getPlatform(entry))
+			r.Target = b.getTargets(entry)
+			r.Minify = entryConfig.Minify
+			r.Sourcemap = entryConfig.Sourcemap
+			r.Conditions = append(append([]string{}, entryConfig.ExportConditions...), entry.Conditions...)
+			r.Env = b.getEnv(entry)
+		}
+
+		resolved = append(resolved, r)
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,256 @@
+package esbuild
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"squish/internal/config"
+	"squish/internal/utils"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// sizeCachePath keeps the sizes of the previous build, to show how much each file changed,
+// relative to the package directory
+var sizeCachePath = filepath.Join("node_modules", ".cache", "squish", "sizes.json")
+
+// sizeCacheFile returns the size cache of a package. Packages without a node_modules directory,
+// e.g. workspace packages with hoisted dependencies, keep it in the user cache directory instead,
+// so none is created for it. It returns "" when there is nowhere to keep the cache.
+func sizeCacheFile(packageDir string) string {
+	if info, err := os.Stat(filepath.Join(packageDir, "node_modules")); err == nil && info.IsDir() {
+		return filepath.Join(packageDir, sizeCachePath)
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	absDir, err := filepath.Abs(packageDir)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(absDir))
+	return filepath.Join(cacheDir, "squish", "sizes", hex.EncodeToString(hash[:8])+".json")
+}
+
+// fileSizes are the sizes of an output file in bytes
+type fileSizes struct {
+	Raw    int `json:"raw"`
+	Gzip   int `json:"gzip"`
+	Brotli int `json:"brotli"`
+}
+
+// measuredFile is an output file as it was when it was measured
+type measuredFile struct {
+	modTime time.Time
+	sizes   fileSizes
+}
+
+func (s fileSizes) get(compression config.Compression) int {
+	switch compression {
+	case config.CompressionGzip:
+		return s.Gzip
+	case config.CompressionBrotli:
+		return s.Brotli
+	default:
+		return s.Raw
+	}
+}
+
+// reportSizes prints the raw, gzip and brotli sizes of every file the builds wrote to dist along
+// with the change since the previous build, and fails when a file exceeds its budget.
+func (b *Bundler) reportSizes(w io.Writer) error {
+	budgets, err := config.ParseBudgets(b.config.Budgets)
+	if err != nil {
+		return err
+	}
+
+	files, err := b.writtenFiles()
+	if err != nil {
+		return err
+	}
+
+	// Compressing every file again after each rebuild is slow, so only the files the build
+	// rewrote are measured
+	measured := make(map[string]measuredFile, len(files))
+	sizes := make(map[string]fileSizes, len(files))
+	for _, file := range files {
+		path := filepath.FromSlash(file)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(b.config.PackageDir, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if previous, ok := b.measured[file]; ok && previous.modTime.Equal(info.ModTime()) && previous.sizes.Raw == int(info.Size()) {
+			measured[file] = previous
+			sizes[file] = previous.sizes
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sizes[file] = measure(data)
+		measured[file] = measuredFile{modTime: info.ModTime(), sizes: sizes[file]}
+	}
+	b.measured = measured
+
+	cachePath := sizeCacheFile(b.config.PackageDir)
+	previous := make(map[string]fileSizes)
+	if cachePath != "" {
+		previous = readSizeCache(cachePath)
+	}
+	printSizes(w, files, sizes, previous)
+
+	if cachePath != "" {
+		if err := writeSizeCache(cachePath, sizes); err != nil {
+			utils.Log("Error writing size cache: ", err)
+		}
+	}
+
+	return checkBudgets(budgets, files, sizes)
+}
+
+// writtenFiles returns the files written by the builds of the package, relative to the package
+// directory. Files left in dist by earlier builds are not included.
+func (b *Bundler) writtenFiles() ([]string, error) {
+	root, err := filepath.Abs(b.config.PackageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seen := make(map[string]bool)
+	files := []string{}
+	for _, written := range b.written {
+		for _, path := range written {
+			if abs, err := filepath.Abs(path); err == nil {
+				if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+					path = rel
+				}
+			}
+			file := filepath.ToSlash(path)
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// measure returns the size of data as is and compressed the way servers commonly compress it.
+func measure(data []byte) fileSizes {
+	var gz bytes.Buffer
+	gzipWriter, _ := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	gzipWriter.Write(data)
+	gzipWriter.Close()
+
+	var br bytes.Buffer
+	brotliWriter := brotli.NewWriterLevel(&br, brotli.BestCompression)
+	brotliWriter.Write(data)
+	brotliWriter.Close()
+
+	return fileSizes{Raw: len(data), Gzip: gz.Len(), Brotli: br.Len()}
+}
+
+func printSizes(w io.Writer, files []string, sizes, previous map[string]fileSizes) {
+	if len(files) == 0 {
+		return
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "File\tSize\tGzip\tBrotli\tChange")
+	for _, file := range files {
+		current := sizes[file]
+		change := "new"
+		if prev, ok := previous[file]; ok {
+			change = formatDelta(current.Raw - prev.Raw)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", file, utils.FormatBytes(current.Raw), utils.FormatBytes(current.Gzip), utils.FormatBytes(current.Brotli), change)
+	}
+	table.Flush()
+}
+
+func formatDelta(delta int) string {
+	switch {
+	case delta > 0:
+		return "+" + utils.FormatBytes(delta)
+	case delta < 0:
+		return "-" + utils.FormatBytes(-delta)
+	default:
+		return "-"
+	}
+}
+
+// checkBudgets returns an error listing every file that exceeds a budget matching it.
+func checkBudgets(budgets []config.Budget, files []string, sizes map[string]fileSizes) error {
+	exceeded := []string{}
+	for _, budget := range budgets {
+		matched := false
+		for _, file := range files {
+			if !budget.Matches(file) {
+				continue
+			}
+			matched = true
+
+			size := sizes[file].get(budget.Compression)
+			if size > budget.Limit {
+				exceeded = append(exceeded, fmt.Sprintf("%s is %s%s, over its budget of %s", file, utils.FormatBytes(size), compressionSuffix(budget.Compression), utils.FormatBytes(budget.Limit)))
+			}
+		}
+		if !matched {
+			utils.Log("No output file matches the size budget for ", budget.Output)
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return fmt.Errorf("size budget exceeded:\n  %s", strings.Join(exceeded, "\n  "))
+	}
+	return nil
+}
+
+func compressionSuffix(compression config.Compression) string {
+	if compression == config.CompressionNone {
+		return ""
+	}
+	return " (" + string(compression) + ")"
+}
+
+func readSizeCache(path string) map[string]fileSizes {
+	sizes := make(map[string]fileSizes)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sizes
+	}
+	// A broken cache only loses the changes of this build
+	_ = json.Unmarshal(data, &sizes)
+	return sizes
+}
+
+func writeSizeCache(path string, sizes map[string]fileSizes) error {
+	data, err := json.MarshalIndent(sizes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,213 @@
+package esbuild
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"squish/internal/config"
+	"squish/internal/testutil"
+	"strings"
+	"testing"
+)
+
+// useTempUserCache points the user cache directory at a temporary directory and returns it.
+func useTempUserCache(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("LocalAppData", dir)
+	return dir
+}
+
+func TestSizeCacheFile(t *testing.T) {
+	userCache := useTempUserCache(t)
+
+	withNodeModules := t.TempDir()
+	if err := os.Mkdir(filepath.Join(withNodeModules, "node_modules"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sizeCacheFile(withNodeModules), filepath.Join(withNodeModules, sizeCachePath); got != want {
+		t.Errorf("sizeCacheFile() = %q, want %q", got, want)
+	}
+
+	a, b := t.TempDir(), t.TempDir()
+	cacheA, cacheB := sizeCacheFile(a), sizeCacheFile(b)
+	if !strings.HasPrefix(cacheA, userCache) || !strings.HasPrefix(cacheB, userCache) {
+		t.Errorf("sizeCacheFile() = %q and %q, want files in the user cache directory %q", cacheA, cacheB, userCache)
+	}
+	if cacheA == cacheB {
+		t.Errorf("packages %q and %q share the size cache %q", a, b, cacheA)
+	}
+	if cacheA != sizeCacheFile(a) {
+		t.Errorf("size cache of %q changes between calls", a)
+	}
+}
+
+func TestReportSizes(t *testing.T) {
+	useTempUserCache(t)
+	dir := t.TempDir()
+	dist := filepath.Join(dir, "dist")
+	if err := os.MkdirAll(filepath.Join(dir, "node_modules"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dist, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	index := filepath.Join(dist, "index.js")
+	if err := os.WriteFile(index, []byte(strings.Repeat("console.log(1);\n", 100)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBundler(&BundlerConfig{PackageDir: dir, DistDir: "dist", Budgets: map[string]string{}}, nil)
+	b.recordWritten("dist/index.js", []string{index})
+	var out bytes.Buffer
+	if err := b.reportSizes(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "new") {
+		t.Errorf("first report does not mark the file as new:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, sizeCachePath)); err != nil {
+		t.Errorf("size cache not written to the package directory: %v", err)
+	}
+
+	// Files the build did not rewrite keep their measured sizes
+	file := "dist/index.js"
+	measured := b.measured[file]
+	measured.sizes.Gzip = 1
+	b.measured[file] = measured
+	out.Reset()
+	if err := b.reportSizes(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.measured[file].sizes.Gzip; got != 1 {
+		t.Errorf("unchanged file was measured again, gzip size %d", got)
+	}
+
+	// Rewritten files are measured again and compared to the previous build
+	if err := os.WriteFile(index, []byte(strings.Repeat("console.log(1);\n", 200)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := b.reportSizes(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.measured[file].sizes; got.Raw != 3200 || got.Gzip == 1 {
+		t.Errorf("rewritten file sizes = %+v", got)
+	}
+	if !strings.Contains(out.String(), "+1.6") {
+		t.Errorf("report does not show the change:\n%s", out.String())
+	}
+}
+
+func TestReportSizesBudgets(t *testing.T) {
+	useTempUserCache(t)
+	dir := t.TempDir()
+	dist := filepath.Join(dir, "dist")
+	if err := os.MkdirAll(dist, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dist, "index.js"), bytes.Repeat([]byte("x"), 2048), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		budget  string
+		wantErr bool
+	}{
+		{budget: "1kb", wantErr: true},
+		{budget: "4kb"},
+		{budget: "1kb gz"},
+		{budget: "1b brotli", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.budget, func(t *testing.T) {
+			b := NewBundler(&BundlerConfig{PackageDir: dir, DistDir: dist, Budgets: map[string]string{"dist/index.js": tt.budget}}, nil)
+			b.recordWritten("dist/index.js", []string{filepath.Join(dist, "index.js")})
+			err := b.reportSizes(&bytes.Buffer{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("reportSizes() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReportSizesWrittenFiles(t *testing.T) {
+	useTempUserCache(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"package.json":            `{"name": "pkg", "type": "module", "exports": {".": "./dist/index.js", "./b": "./dist/b.js", "./c": "./dist/c.cjs"}}`,
+		"src/index.js":            `import { shared } from "./shared.js"; export const a = shared + 1;`,
+		"src/b.js":                `import { shared } from "./shared.js"; export const b = shared + 2;`,
+		"src/c.js":                `export const c = 3;`,
+		"src/shared.js":           `export const shared = 1;`,
+		"dist/_chunks/old-ABC.js": `export const removed = 1;`,
+		"dist/removed.js":         `export const removed = 1;`,
+	}
+	testutil.WriteFiles(t, dir, files)
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	pkg, err := config.ReadPackageJSON(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBundler(&BundlerConfig{PackageDir: dir, SrcDir: "src", DistDir: "dist", Budgets: map[string]string{"dist/*.js": "1kb"}}, pkg)
+	defer b.Dispose()
+
+	// reported returns the files in the size table of the output
+	reported := func(out string) []string {
+		files := []string{}
+		inTable := false
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 0 || strings.HasPrefix(fields[0], "["):
+				inTable = false
+			case fields[0] == "File":
+				inTable = true
+			case inTable:
+				files = append(files, fields[0])
+			}
+		}
+		return files
+	}
+
+	var bundleErr error
+	out := captureStdout(t, func() { bundleErr = b.Bundle() })
+	if bundleErr != nil {
+		t.Fatal(bundleErr)
+	}
+	got := reported(out)
+	if !slices.Contains(got, "dist/index.js") || !slices.Contains(got, "dist/b.js") || !slices.Contains(got, "dist/c.cjs") {
+		t.Errorf("report is missing outputs of the build:\n%s", out)
+	}
+	for _, file := range got {
+		if file == "dist/removed.js" || strings.Contains(file, "old-ABC") {
+			t.Errorf("report includes %s, which the build did not write:\n%s", file, out)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "node_modules")); !os.IsNotExist(err) {
+		t.Errorf("size report created node_modules in a package without one")
+	}
+
+	// Outputs of builds not affected by a change are still reported
+	var rebuildErr error
+	out = captureStdout(t, func() { _, rebuildErr = b.Rebuild([]string{filepath.Join(dir, "src", "c.js")}) })
+	if rebuildErr != nil {
+		t.Fatal(rebuildErr)
+	}
+	if rebuilt := reported(out); !slices.Equal(rebuilt, got) {
+		t.Errorf("report after a rebuild = %q, want %q", rebuilt, got)
+	}
+}
@@ -0,0 +1,124 @@
+package esbuild
+
+import (
+	"fmt"
+	"github.com/evanw/esbuild/pkg/api"
+	"regexp"
+	"squish/internal/config"
+	"squish/internal/utils"
+	"strings"
+)
+
+// defaultTarget is used when neither --target nor the package.json describe the environments
+const defaultTarget = "es2022"
+
+var esTargets = map[string]api.Target{
+	"esnext": api.ESNext,
+	"es5":    api.ES5,
+	"es6":    api.ES2015,
+	"es2015": api.ES2015,
+	"es2016": api.ES2016,
+	"es2017": api.ES2017,
+	"es2018": api.ES2018,
+	"es2019": api.ES2019,
+	"es2020": api.ES2020,
+	"es2021": api.ES2021,
+	"es2022": api.ES2022,
+	"es2023": api.ES2023,
+	"es2024": api.ES2024,
+}
+
+var engineNames = map[string]api.EngineName{
+	"chrome":  api.EngineChrome,
+	"deno":    api.EngineDeno,
+	"edge":    api.EngineEdge,
+	"firefox": api.EngineFirefox,
+	"hermes":  api.EngineHermes,
+	"ie":      api.EngineIE,
+	"ios":     api.EngineIOS,
+	"node":    api.EngineNode,
+	"opera":   api.EngineOpera,
+	"rhino":   api.EngineRhino,
+	"safari":  api.EngineSafari,
+}
+
+var engineTargetPattern = regexp.MustCompile(`^([a-z]+)(\d+(?:\.\d+){0,2})$`)
+
+// parseTargets converts target strings such as "es2020", "node18.12" or "chrome100" into the ES
+// version and engine list esbuild compiles for. Unknown targets are an error.
+func parseTargets(targets []string) (api.Target, []api.Engine, error) {
+	esTarget := api.DefaultTarget
+	engines := []api.Engine{}
+
+	for _, t := range targets {
+		t = strings.ToLower(strings.TrimSpace(t))
+
+		if target, ok := esTargets[t]; ok {
+			if esTarget != api.DefaultTarget && esTarget != target {
+				return 0, nil, fmt.Errorf("conflicting ES targets %q", targets)
+			}
+			esTarget = target
+			continue
+		}
+
+		match := engineTargetPattern.FindStringSubmatch(t)
+		if match == nil {
+			return 0, nil, fmt.Errorf("unknown target %q, expected an ES version such as es2022 or an engine with a version such as node18 or chrome100", t)
+		}
+		engine, ok := engineNames[match[1]]
+		if !ok {
+			return 0, nil, fmt.Errorf("unknown target engine %q in %q", match[1], t)
+		}
+		engines = append(engines, api.Engine{Name: engine, Version: match[2]})
+	}
+
+	return esTarget, engines, nil
+}
+
+// getTargets returns the targets an entry is compiled for. Configured targets apply as they are,
+// otherwise node builds follow engines.node and browser builds follow browserslist.
+func (b *Bundler) getTargets(entry config.ExportEntry) []string {
+	if target := b.entryConfig(entry).Target; len(target) > 0 {
+		return target
+	}
+
+	switch b.getPlatform(entry) {
+	case api.PlatformNode:
+		if nodeTarget := b.pkg.GetNodeTarget(); nodeTarget != "" {
+			return []string{nodeTarget}
+		}
+	case api.PlatformBrowser:
+		if browserTargets, _ := b.pkg.GetBrowserTargets(); len(browserTargets) > 0 {
+			return browserTargets
+		}
+	}
+
+	return []string{defaultTarget}
+}
+
+// checkBrowserslist validates the browserslist targets when an entry is built for browsers
+// without configured targets, and logs the queries that are not supported.
+func (b *Bundler) checkBrowserslist(entries []config.ExportEntry) error {
+	for _, entry := range entries {
+		if entry.Type == config.PackageTypeTypes || len(b.entryConfig(entry).Target) > 0 || b.getPlatform(entry) != api.PlatformBrowser {
+			continue
+		}
+
+		browserTargets, unsupported := b.pkg.GetBrowserTargets()
+		if _, _, err := parseTargets(browserTargets); err != nil {
+			return fmt.Errorf("browserslist: %w", err)
+		}
+		for _, query := range unsupported {
+			utils.Log("Ignoring unsupported browserslist query: ", query)
+		}
+		break
+	}
+	return nil
+}
+
+// getEsbuildTarget returns the ES version and engines for an entry. Targets are validated
+// before bundling, so parse errors cannot occur here.
+func (b *Bundler) getEsbuildTarget(entry config.ExportEntry) (api.Target, []api.Engine) {
+	target, engines, _ := parseTargets(b.getTargets(entry))
+	return target, engines
+}
@@ -0,0 +1,247 @@
+package esbuild
+
+import (
+	"github.com/evanw/esbuild/pkg/api"
+	"io"
+	"os"
+	"reflect"
+	"squish/internal/config"
+	"strings"
+	"testing"
+)
+
+// captureStdout returns what f logs
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestCheckBrowserslist(t *testing.T) {
+	browserslist := config.Browserslist{"chrome >= 90", "last 2 versions"}
+	tests := []struct {
+		name     string
+		platform string
+		target   []string
+		entries  []config.ExportEntry
+		wantLogs int
+	}{
+		{
+			name:     "browser entries",
+			platform: "browser",
+			entries:  []config.ExportEntry{{OutputPath: "./dist/index.js"}},
+			wantLogs: 1,
+		},
+		{
+			name:     "node entries",
+			platform: "node",
+			entries:  []config.ExportEntry{{OutputPath: "./dist/index.js"}},
+		},
+		{
+			name:     "node entries with a browser condition",
+			platform: "node",
+			entries:  []config.ExportEntry{{OutputPath: "./dist/index.js"}, {OutputPath: "./dist/browser.js", Platform: "browser"}},
+			wantLogs: 1,
+		},
+		{
+			name:     "configured targets",
+			platform: "browser",
+			target:   []string{"es2020"},
+			entries:  []config.ExportEntry{{OutputPath: "./dist/index.js"}},
+		},
+		{
+			name:     "declarations only",
+			platform: "browser",
+			entries:  []config.ExportEntry{{OutputPath: "./dist/index.d.ts", Type: config.PackageTypeTypes}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBundler(&BundlerConfig{Platform: tt.platform, Target: tt.target}, &config.PackageJSON{Browserslist: browserslist})
+			out := captureStdout(t, func() {
+				// Rebuilds do not log the queries again
+				for i := 0; i < 3; i++ {
+					if err := b.checkEntries(tt.entries); err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+			if got := strings.Count(out, "Ignoring unsupported browserslist query: last 2 versions"); got != tt.wantLogs {
+				t.Errorf("logged %d times, want %d:\n%s", got, tt.wantLogs, out)
+			}
+		})
+	}
+}
+
+func TestCheckBrowserslistAfterReconfigure(t *testing.T) {
+	pkg := &config.PackageJSON{Browserslist: config.Browserslist{"defaults"}}
+	entries := []config.ExportEntry{{OutputPath: "./dist/index.js"}}
+	b := NewBundler(&BundlerConfig{Platform: "browser"}, pkg)
+
+	out := captureStdout(t, func() {
+		b.checkEntries(entries)
+		b.Reconfigure(&BundlerConfig{Platform: "browser"}, pkg)
+		b.checkEntries(entries)
+	})
+	if got := strings.Count(out, "Ignoring unsupported browserslist query: defaults"); got != 2 {
+		t.Errorf("logged %d times, want once per configuration:\n%s", got, out)
+	}
+}
+
+func TestWarnUndeclaredConditions(t *testing.T) {
+	entries := []config.ExportEntry{
+		{OutputPath: "./dist/index.mjs", From: "exports.import"},
+		{OutputPath: "./dist/worker.mjs", From: "exports.worker", Conditions: []string{"worker", "development"}},
+		{OutputPath: "./dist/source.ts", From: "exports.source", Conditions: []string{"source"}},
+		{OutputPath: "./dist/typo.mjs", From: "exports.improt", Conditions: []string{"improt"}},
+		{OutputPath: "./dist/typo.cjs", From: "exports.node.improt", Conditions: []string{"node", "improt"}},
+	}
+	tests := []struct {
+		name   string
+		config BundlerConfig
+		want   []string
+	}{
+		{
+			name: "undeclared",
+			want: []string{`"source" in exports.source`, `"improt" in exports.improt`},
+		},
+		{
+			name:   "declared as custom conditions",
+			config: BundlerConfig{CustomConditions: []string{"source"}},
+			want:   []string{`"improt" in exports.improt`},
+		},
+		{
+			name:   "declared as export conditions",
+			config: BundlerConfig{ExportConditions: []string{"source", "improt"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBundler(&tt.config, &config.PackageJSON{})
+			out := captureStdout(t, func() { b.warnUndeclaredConditions(entries) })
+			if got := strings.Count(out, "Unknown export condition"); got != len(tt.want) {
+				t.Errorf("logged %d warnings, want %d:\n%s", got, len(tt.want), out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("log does not contain %s:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name        string
+		targets     []string
+		wantTarget  api.Target
+		wantEngines []api.Engine
+		wantErr     string
+	}{
+		{
+			name:        "none",
+			wantTarget:  api.DefaultTarget,
+			wantEngines: []api.Engine{},
+		},
+		{
+			name:        "ES version",
+			targets:     []string{"ES2020"},
+			wantTarget:  api.ES2020,
+			wantEngines: []api.Engine{},
+		},
+		{
+			name:        "engines",
+			targets:     []string{"node18.12", " chrome100 ", "ios14.5.1"},
+			wantTarget:  api.DefaultTarget,
+			wantEngines: []api.Engine{{Name: api.EngineNode, Version: "18.12"}, {Name: api.EngineChrome, Version: "100"}, {Name: api.EngineIOS, Version: "14.5.1"}},
+		},
+		{
+			name:        "ES version and engines",
+			targets:     []string{"es6", "node12", "es2015"},
+			wantTarget:  api.ES2015,
+			wantEngines: []api.Engine{{Name: api.EngineNode, Version: "12"}},
+		},
+		{
+			name:    "conflicting ES versions",
+			targets: []string{"es2020", "es2022"},
+			wantErr: "conflicting ES targets",
+		},
+		{
+			name:    "unknown target",
+			targets: []string{"latest"},
+			wantErr: `unknown target "latest"`,
+		},
+		{
+			name:    "unknown engine",
+			targets: []string{"netscape4"},
+			wantErr: `unknown target engine "netscape"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, engines, err := parseTargets(tt.targets)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target != tt.wantTarget {
+				t.Errorf("target = %v, want %v", target, tt.wantTarget)
+			}
+			if !reflect.DeepEqual(engines, tt.wantEngines) {
+				t.Errorf("engines = %+v, want %+v", engines, tt.wantEngines)
+			}
+		})
+	}
+}
+
+func TestGetTargets(t *testing.T) {
+	pkg := &config.PackageJSON{
+		Engines:      map[string]string{"node": ">=18"},
+		Browserslist: config.Browserslist{"chrome 100"},
+	}
+	tests := []struct {
+		name   string
+		config BundlerConfig
+		pkg    *config.PackageJSON
+		entry  config.ExportEntry
+		want   []string
+	}{
+		{name: "node entry", pkg: pkg, want: []string{"node18"}},
+		{name: "browser entry", pkg: pkg, entry: config.ExportEntry{Platform: "browser"}, want: []string{"chrome100"}},
+		{name: "neutral entry", pkg: pkg, entry: config.ExportEntry{Platform: "neutral"}, want: []string{defaultTarget}},
+		{name: "configured targets", config: BundlerConfig{Target: []string{"es2020"}}, pkg: pkg, want: []string{"es2020"}},
+		{name: "override targets", pkg: pkg, entry: config.ExportEntry{Options: config.EntryOptions{Target: []string{"node20"}}}, want: []string{"node20"}},
+		{name: "package without targets", pkg: &config.PackageJSON{}, want: []string{defaultTarget}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBundler(&tt.config, tt.pkg)
+			if got := b.getTargets(tt.entry); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getTargets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
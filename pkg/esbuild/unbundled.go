@@ -0,0 +1,405 @@
+package esbuild
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/evanw/esbuild/pkg/api"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"squish/internal/utils"
+	"strings"
+	"sync"
+)
+
+// transpiledExtensions are the source extensions of the files transpiled in unbundled mode.
+// Other files imported from the source directory, like JSON, are copied as they are.
+var transpiledExtensions = map[string]bool{
+	".ts":  true,
+	".tsx": true,
+	".mts": true,
+	".cts": true,
+	".js":  true,
+	".jsx": true,
+	".mjs": true,
+	".cjs": true,
+}
+
+// unbundledPluginData marks the resolutions the unbundled plugin starts itself, so it does not
+// handle them again
+const unbundledPluginData = "squish-unbundled"
+
+// UnbundledPlugin keeps every import of an unbundled build external. Imports of files in the
+// source directory are rewritten to the file emitted for them with the dist extension, e.g.
+// "./utils" to "./utils.mjs", and passed to found so they are transpiled as well. Node builtins
+// are handled like in bundled builds.
+func UnbundledPlugin(srcDir, distExtension string, found func(path string)) Plugin {
+	absSrcDir, _ := filepath.Abs(srcDir)
+
+	return NewPluginBuilder("unbundled").
+		Setup(func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: ".*"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				// Resolutions started by this plugin or the browser builtins plugin are left to esbuild,
+				// so the two do not resolve each other's resolutions endlessly
+				if args.Kind == api.ResolveEntryPoint || args.PluginData == unbundledPluginData || args.PluginData == browserBuiltinsPluginData {
+					return api.OnResolveResult{}, nil
+				}
+
+				resolved := build.Resolve(args.Path, api.ResolveOptions{
+					Importer:   args.Importer,
+					ResolveDir: args.ResolveDir,
+					Kind:       args.Kind,
+					PluginData: unbundledPluginData,
+					With:       args.With,
+				})
+				if isNodeBuiltin(args.Path) {
+					// The builtin plugins of the build fail browser builds or normalize the "node:"
+					// prefix for the targets, while polyfills and stubs are left to the bundler of the consumer
+					if len(resolved.Errors) > 0 {
+						return api.OnResolveResult{Errors: resolved.Errors}, nil
+					}
+					if resolved.External {
+						return api.OnResolveResult{Path: resolved.Path, External: true}, nil
+					}
+					return api.OnResolveResult{Path: args.Path, External: true}, nil
+				}
+				if len(resolved.Errors) > 0 || resolved.External || !containsPath(absSrcDir, resolved.Path) || strings.Contains(resolved.Path, "node_modules") {
+					return api.OnResolveResult{Path: args.Path, External: true}, nil
+				}
+
+				found(resolved.Path)
+
+				target := resolved.Path
+				if ext := filepath.Ext(target); transpiledExtensions[ext] {
+					target = strings.TrimSuffix(target, ext) + distExtension
+				}
+				specifier, err := filepath.Rel(filepath.Dir(args.Importer), target)
+				if err != nil {
+					return api.OnResolveResult{}, err
+				}
+				specifier = filepath.ToSlash(specifier)
+				if !strings.HasPrefix(specifier, ".") {
+					specifier = "./" + specifier
+				}
+				return api.OnResolveResult{Path: specifier, External: true}, nil
+			})
+		}).
+		Build()
+}
+
+// unbundledGroups groups entries by the settings of their build, as each file reachable from the
+// entries of a group is transpiled once with those settings.
+func unbundledGroups(entries []resolvedEntry) []*splitGroup {
+	groups := []*splitGroup{}
+	for _, resolved := range entries {
+		variant := fmt.Sprintf("%s|%s", resolved.entry.Type, entryVariant(resolved.entry))
+
+		var group *splitGroup
+		for _, g := range groups {
+			if g.variant == variant && g.distExtension == resolved.sourcePath.DistExtension {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &splitGroup{variant: variant, distExtension: resolved.sourcePath.DistExtension}
+			groups = append(groups, group)
+		}
+		group.entries = append(group.entries, resolved)
+	}
+	return groups
+}
+
+// checkUnbundledGroups fails when two groups would write the files they share to the same paths.
+func checkUnbundledGroups(groups []*splitGroup) error {
+	for i, group := range groups {
+		for _, other := range groups[:i] {
+			if group.distExtension == other.distExtension {
+				return fmt.Errorf("unbundled builds of %s and %s would both write %s files for their shared modules, use a different extension for one of them",
+					other.outputs(), group.outputs(), group.distExtension)
+			}
+		}
+	}
+	return nil
+}
+
+// transpileGroup transpiles every file in the source directory reachable from the entries of the
+// group to its own output file, mirroring the layout of the source directory. Files are found
+// through the imports of the files transpiled before them.
+func (b *Bundler) transpileGroup(w io.Writer, group *splitGroup) error {
+	outputs := group.outputs()
+	executables := make(map[string]bool)
+	pending := []string{}
+	seen := make(map[string]bool)
+	for _, resolved := range group.entries {
+		input, err := filepath.Abs(resolved.sourcePath.Input)
+		if err != nil {
+			return err
+		}
+		if !seen[input] {
+			seen[input] = true
+			pending = append(pending, input)
+		}
+		if resolved.entry.IsExecutable {
+			executables[filepath.Clean(filepath.Join(b.config.DistDir, utils.GetDistRelativePath(resolved.entry.OutputPath, b.config.DistDir)))] = true
+		}
+	}
+
+	files := make(map[string][]byte)
+	assets := []string{}
+	inputs := make(map[string]bool)
+
+	for len(pending) > 0 {
+		if b.canceled.Load() {
+			return ErrBuildCanceled
+		}
+
+		// esbuild resolves imports concurrently
+		var foundMu sync.Mutex
+		found := []string{}
+		buildOptions := b.getBuildOptions(group.entries[0].entry, nil)
+		buildOptions.Plugins = append([]api.Plugin{createEsbuildPlugin(UnbundledPlugin(b.config.SrcDir, group.distExtension, func(path string) {
+			foundMu.Lock()
+			found = append(found, path)
+			foundMu.Unlock()
+		}))}, buildOptions.Plugins...)
+		buildOptions.EntryPoints = pending
+		buildOptions.Outbase = b.config.SrcDir
+		buildOptions.Outdir = b.config.DistDir
+		buildOptions.Write = false
+		if group.distExtension != ".js" {
+			buildOptions.OutExtension = map[string]string{".js": group.distExtension}
+		}
+
+		result := api.Build(buildOptions)
+		if len(result.Errors) > 0 {
+			printBuildErrors(w, result.Errors)
+			b.recordTranspiledInputs(outputs, nil)
+			return fmt.Errorf("build failed for %s", outputs)
+		}
+		if len(result.Warnings) > 0 {
+			printBuildWarnings(w, result.Warnings)
+		}
+
+		for _, file := range result.OutputFiles {
+			files[file.Path] = file.Contents
+		}
+		for input := range metafileInputs(result.Metafile) {
+			inputs[input] = true
+		}
+
+		pending = pending[:0]
+		sort.Strings(found)
+		for _, path := range found {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			if transpiledExtensions[filepath.Ext(path)] {
+				pending = append(pending, path)
+			} else {
+				assets = append(assets, path)
+			}
+		}
+	}
+
+	written := make([]string, 0, len(files)+len(assets))
+	for path, contents := range files {
+		written = append(written, path)
+		mode := os.FileMode(0644)
+		if executables[displayPath(path)] {
+			if !bytes.HasPrefix(contents, []byte("#!")) {
+				contents = append([]byte("#!/usr/bin/env node\n"), contents...)
+			}
+			mode = 0755
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := os.WriteFile(path, contents, mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	for _, asset := range assets {
+		outfile, err := b.copyAsset(asset)
+		if err != nil {
+			return err
+		}
+		written = append(written, outfile)
+		inputs[asset] = true
+	}
+
+	b.recordTranspiledInputs(outputs, inputs)
+	b.recordWritten(outputs, written)
+	return nil
+}
+
+// copyAsset copies a file imported from the source directory that is not transpiled to the same
+// place in the dist directory, and returns the path of the copy.
+func (b *Bundler) copyAsset(path string) (string, error) {
+	absSrcDir, err := filepath.Abs(b.config.SrcDir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absSrcDir, path)
+	if err != nil {
+		return "", err
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	outfile := filepath.Join(b.config.DistDir, rel)
+	if err := os.MkdirAll(filepath.Dir(outfile), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return outfile, os.WriteFile(outfile, contents, 0644)
+}
+
+// recordTranspiledInputs keeps the inputs of an unbundled build for deciding which builds changes
+// affect, nil after a failed build.
+func (b *Bundler) recordTranspiledInputs(outputs string, inputs map[string]bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.transpiled[outputs] = inputs
+}
+
+// placeUnbundledDeclarations copies every declaration tsc emitted for the source directory next to
+// the transpiled files, with relative imports pointing at the emitted files. Types entries whose
+// path does not mirror their source get a copy of its declaration. It returns the written files.
+func (b *Bundler) placeUnbundledDeclarations(tmpDir string, entries []declarationEntry) ([]string, error) {
+	root, err := b.emittedDeclarationRoot(tmpDir, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	written := []string{}
+	write := func(path, outfile string) error {
+		written = append(written, outfile)
+		return writeDeclaration(path, outfile)
+	}
+
+	if root != "" {
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !isDeclarationFile(path) {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			return write(path, filepath.Join(b.config.DistDir, rel))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Hand-written declarations are not emitted by tsc, so they are copied from the source directory
+	err = filepath.Walk(b.config.SrcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isDeclarationFile(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(b.config.SrcDir, path)
+		if err != nil {
+			return err
+		}
+		return write(path, filepath.Join(b.config.DistDir, rel))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range entries {
+		outfile := filepath.Join(b.config.DistDir, utils.GetDistRelativePath(d.entry.OutputPath, b.config.DistDir))
+
+		source := d.sourcePath.Input
+		if !strings.HasPrefix(d.sourcePath.SrcExtension, ".d.") {
+			emitted, emittedRoot, err := findEmittedDeclaration(tmpDir, b.config.SrcDir, d.sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			source = emitted
+			// Declarations mirroring their source were written above
+			if rel, err := filepath.Rel(emittedRoot, emitted); err == nil && filepath.Join(b.config.DistDir, rel) == filepath.Clean(outfile) {
+				continue
+			}
+		} else if filepath.Join(b.config.DistDir, utils.RelOrAbs(b.config.SrcDir, source)) == filepath.Clean(outfile) {
+			continue
+		}
+
+		if err := write(source, outfile); err != nil {
+			return nil, fmt.Errorf("failed to write declaration %s: %w", d.entry.OutputPath, err)
+		}
+	}
+
+	return written, nil
+}
+
+func isDeclarationFile(path string) bool {
+	return strings.HasSuffix(path, ".d.ts") || strings.HasSuffix(path, ".d.mts") || strings.HasSuffix(path, ".d.cts")
+}
+
+// writeDeclaration copies a declaration file, rewriting its relative imports so they point at
+// the emitted JavaScript files, as node16 module resolution requires.
+func writeDeclaration(path, outfile string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	rewritten := declarationImportPattern.ReplaceAllStringFunc(string(contents), func(match string) string {
+		parts := declarationImportPattern.FindStringSubmatch(match)
+		return parts[1] + parts[2] + rewriteDeclarationSpecifier(filepath.Dir(path), parts[3]) + parts[2]
+	})
+
+	if err := os.MkdirAll(filepath.Dir(outfile), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(outfile, []byte(rewritten), 0644)
+}
+
+// declarationImportPattern matches the relative specifiers of imports, exports and import types
+var declarationImportPattern = regexp.MustCompile(`(from\s*|import\s*\(\s*|import\s+)(["'])(\.\.?(?:/[^"'\n]*)?)["']`)
+
+// declarationSpecifierExtensions maps declaration extensions to the extension of the JavaScript
+// file they describe
+var declarationSpecifierExtensions = [][2]string{{".d.mts", ".mjs"}, {".d.cts", ".cjs"}, {".d.ts", ".js"}}
+
+// rewriteDeclarationSpecifier adds the JavaScript extension to a relative import of another
+// declaration, e.g. "./utils" to "./utils.js" or "./dir" to "./dir/index.js".
+func rewriteDeclarationSpecifier(dir, specifier string) string {
+	switch filepath.Ext(specifier) {
+	case ".js", ".mjs", ".cjs", ".json":
+		return specifier
+	case ".ts", ".tsx":
+		return strings.TrimSuffix(specifier, filepath.Ext(specifier)) + ".js"
+	case ".mts":
+		return strings.TrimSuffix(specifier, ".mts") + ".mjs"
+	case ".cts":
+		return strings.TrimSuffix(specifier, ".cts") + ".cjs"
+	}
+
+	base := filepath.Join(dir, filepath.FromSlash(specifier))
+	for _, ext := range declarationSpecifierExtensions {
+		if utils.FileExists(base + ext[0]) {
+			return specifier + ext[1]
+		}
+	}
+	for _, ext := range declarationSpecifierExtensions {
+		if utils.FileExists(filepath.Join(base, "index"+ext[0])) {
+			return strings.TrimSuffix(specifier, "/") + "/index" + ext[1]
+		}
+	}
+	return specifier
+}
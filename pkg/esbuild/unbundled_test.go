@@ -0,0 +1,97 @@
+package esbuild
+
+import (
+	"github.com/evanw/esbuild/pkg/api"
+	"path/filepath"
+	"squish/internal/testutil"
+	"strings"
+	"testing"
+)
+
+func TestUnbundledPluginBuiltins(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    string
+		platform  api.Platform
+		plugin    Plugin
+		want      []string
+		wantError string
+	}{
+		{
+			name:     "node: prefix stripped for old Node targets",
+			source:   `import fs from "node:fs"; import path from "path"; console.log(fs, path);`,
+			platform: api.PlatformNode,
+			plugin:   ExternalizeNodeBuiltinsPlugin([]string{"node12"}),
+			want:     []string{`from "fs"`, `from "path"`},
+		},
+		{
+			name:     "node: prefix kept for current Node targets",
+			source:   `import fs from "node:fs"; console.log(fs);`,
+			platform: api.PlatformNode,
+			plugin:   ExternalizeNodeBuiltinsPlugin([]string{"node18"}),
+			want:     []string{`from "node:fs"`},
+		},
+		{
+			name:      "builtin in a browser build",
+			source:    `import fs from "fs"; console.log(fs);`,
+			platform:  api.PlatformBrowser,
+			plugin:    BrowserNodeBuiltinsPlugin(BrowserBuiltinsError, nil),
+			wantError: `Node builtin "fs" cannot be used in a browser build`,
+		},
+		{
+			name:     "polyfilled builtin in a browser build",
+			source:   `import events from "events"; console.log(events);`,
+			platform: api.PlatformBrowser,
+			plugin:   BrowserNodeBuiltinsPlugin(BrowserBuiltinsError, nil),
+			want:     []string{`from "events"`},
+		},
+		{
+			name:     "stubbed builtin in a browser build",
+			source:   `import fs from "node:fs"; console.log(fs);`,
+			platform: api.PlatformBrowser,
+			plugin:   BrowserNodeBuiltinsPlugin(BrowserBuiltinsStub, nil),
+			want:     []string{`from "node:fs"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{
+				"src/index.js":                     tt.source,
+				"node_modules/events/package.json": `{"name": "events", "main": "./events.js"}`,
+				"node_modules/events/events.js":    `module.exports = {};`,
+			}
+			testutil.WriteFiles(t, dir, files)
+
+			result := api.Build(api.BuildOptions{
+				EntryPoints:   []string{filepath.Join(dir, "src", "index.js")},
+				AbsWorkingDir: dir,
+				Bundle:        true,
+				Platform:      tt.platform,
+				Format:        api.FormatESModule,
+				LogLevel:      api.LogLevelSilent,
+				Plugins: []api.Plugin{
+					createEsbuildPlugin(UnbundledPlugin(filepath.Join(dir, "src"), ".js", func(string) {})),
+					createEsbuildPlugin(tt.plugin),
+				},
+			})
+
+			if tt.wantError != "" {
+				if len(result.Errors) == 0 || result.Errors[0].Text != tt.wantError {
+					t.Fatalf("errors = %v, want %q", result.Errors, tt.wantError)
+				}
+				return
+			}
+			if len(result.Errors) > 0 {
+				t.Fatalf("unexpected errors %v", result.Errors)
+			}
+			code := string(result.OutputFiles[0].Contents)
+			for _, want := range tt.want {
+				if !strings.Contains(code, want) {
+					t.Errorf("output does not contain %q:\n%s", want, code)
+				}
+			}
+		})
+	}
+}